@@ -1,35 +1,103 @@
 package consul
 
-import "github.com/pivotal-cf-experimental/destiny/ops"
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+)
+
+var manifestV2Template = ops.NewTemplate(manifestV2)
 
 type ConfigV2 struct {
-	Name string
-	AZs  []string
+	Name   string
+	AZs    []string
+	Format ops.OutputFormat
 }
 
 func NewManifestV2(config ConfigV2) (string, error) {
-	return ops.ApplyOps(manifestV2, []ops.Op{
-		{"replace", "/name", config.Name},
-		{"replace", "/instance_groups/name=consul/azs", config.AZs},
-		{"replace", "/instance_groups/name=testconsumer/azs", config.AZs},
-	})
+	buffer := &bytes.Buffer{}
+	err := WriteManifestV2(buffer, config)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+func WriteManifestV2(w io.Writer, config ConfigV2) error {
+	document, err := newManifestV2(config)
+	if err != nil {
+		return err
+	}
+
+	err = document.Validate()
+	if err != nil {
+		return err
+	}
+
+	return newRenderer(config).WriteDocument(w, document)
 }
 
 func NewManifestV2Windows(config ConfigV2) (string, error) {
-	manifest, err := NewManifestV2(config)
+	buffer := &bytes.Buffer{}
+	err := WriteManifestV2Windows(buffer, config)
 	if err != nil {
 		return "", err
 	}
 
-	return ops.ApplyOps(manifest, []ops.Op{
-		{"replace", "/stemcells/-", map[string]string{
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+func WriteManifestV2Windows(w io.Writer, config ConfigV2) error {
+	document, err := newManifestV2(config)
+	if err != nil {
+		return err
+	}
+
+	testconsumer := ops.NewPath().Key("instance_groups").Name("testconsumer")
+	consulAgent := testconsumer.Key("jobs").Name("consul_agent")
+	consulTestConsumer := testconsumer.Key("jobs").Name("consul-test-consumer")
+
+	document, err = document.ApplyOps([]ops.Op{
+		ops.TestAbsent(ops.NewPath().Key("stemcells").Match("alias", "windows")),
+		ops.Test(consulAgent.Key("release"), "consul"),
+		ops.Test(consulTestConsumer.Key("release"), "consul"),
+		ops.Replace(ops.NewPath().Key("stemcells").Append(), map[string]string{
 			"alias":   "windows",
 			"os":      "windows2012R2",
 			"version": "latest",
-		}},
-		{"replace", "/instance_groups/name=testconsumer/jobs/name=consul_agent/name", "consul_agent_windows"},
-		{"replace", "/instance_groups/name=testconsumer/jobs/name=consul-test-consumer/name", "consul-test-consumer-windows"},
-		{"replace", "/instance_groups/name=testconsumer/vm_extensions?", []string{"50GB_ephemeral_disk"}},
-		{"replace", "/instance_groups/name=testconsumer/stemcell", "windows"},
+		}),
+		ops.Replace(consulAgent.Key("name"), "consul_agent_windows"),
+		ops.Replace(consulTestConsumer.Key("name"), "consul-test-consumer-windows"),
+		ops.Replace(testconsumer.Key("vm_extensions").Optional(), []string{"50GB_ephemeral_disk"}),
+		ops.Replace(testconsumer.Key("stemcell"), "windows"),
 	})
+	if err != nil {
+		return err
+	}
+
+	err = document.Validate()
+	if err != nil {
+		return err
+	}
+
+	return newRenderer(config).WriteDocument(w, document)
+}
+
+func newManifestV2(config ConfigV2) (ops.Document, error) {
+	document, err := manifestV2Template.Document()
+	if err != nil {
+		return ops.Document{}, err
+	}
+
+	return document.ApplyOps([]ops.Op{
+		{Type: "replace", Path: "/name", Value: config.Name},
+		{Type: "replace", Path: "/instance_groups/*/azs", Value: config.AZs},
+	})
+}
+
+func newRenderer(config ConfigV2) ops.Renderer {
+	return ops.Renderer{Format: config.Format, Conventional: true}
 }
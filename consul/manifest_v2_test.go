@@ -1,9 +1,11 @@
 package consul_test
 
 import (
+	"bytes"
 	"io/ioutil"
 
 	"github.com/pivotal-cf-experimental/destiny/consul"
+	"github.com/pivotal-cf-experimental/destiny/ops"
 	"github.com/pivotal-cf-experimental/gomegamatchers"
 
 	. "github.com/onsi/ginkgo"
@@ -24,6 +26,73 @@ var _ = Describe("ManifestV2", func() {
 
 			Expect(manifest).To(gomegamatchers.MatchYAML(consulManifest))
 		})
+
+		It("writes the manifest in the conventional BOSH key order", func() {
+			manifest, err := consul.NewManifestV2(consul.ConfigV2{
+				Name: "some-manifest-name",
+				AZs:  []string{"z1"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifest).To(HavePrefix("name: some-manifest-name\nreleases:\n"))
+			Expect(manifest).To(ContainSubstring("instance_groups:\n- name: consul\n  azs:\n  - z1\n  instances: 1\n"))
+		})
+
+		It("returns a JSON representation when asked to", func() {
+			consulManifest, err := ioutil.ReadFile("fixtures/consul_manifest_v2.yml")
+			Expect(err).NotTo(HaveOccurred())
+
+			manifest, err := consul.NewManifestV2(consul.ConfigV2{
+				Name:   "some-manifest-name",
+				AZs:    []string{"z1", "z2"},
+				Format: ops.JSONOutput,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifest).To(HavePrefix("{\n  \"instance_groups\": [\n"))
+			Expect(manifest).To(gomegamatchers.MatchYAML(consulManifest))
+		})
+
+		Context("failure cases", func() {
+			Context("when no azs are provided", func() {
+				It("returns a validation error", func() {
+					_, err := consul.NewManifestV2(consul.ConfigV2{
+						Name: "some-manifest-name",
+					})
+					Expect(err).To(MatchError(ContainSubstring("/instance_groups/name=consul/azs: azs must not be empty")))
+				})
+			})
+		})
+	})
+
+	Describe("WriteManifestV2", func() {
+		It("writes the consul manifest into the writer", func() {
+			consulManifest, err := ioutil.ReadFile("fixtures/consul_manifest_v2.yml")
+			Expect(err).NotTo(HaveOccurred())
+
+			buffer := &bytes.Buffer{}
+			err = consul.WriteManifestV2(buffer, consul.ConfigV2{
+				Name:   "some-manifest-name",
+				AZs:    []string{"z1", "z2"},
+				Format: ops.CompactJSONOutput,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(HavePrefix(`{"instance_groups":[{`))
+			Expect(buffer.String()).To(HaveSuffix("}\n"))
+			Expect(buffer.String()).To(gomegamatchers.MatchYAML(consulManifest))
+		})
+
+		Context("failure cases", func() {
+			It("does not write anything when the manifest is invalid", func() {
+				buffer := &bytes.Buffer{}
+				err := consul.WriteManifestV2(buffer, consul.ConfigV2{
+					Name: "some-manifest-name",
+				})
+				Expect(err).To(MatchError(ContainSubstring("azs must not be empty")))
+				Expect(buffer.Len()).To(Equal(0))
+			})
+		})
 	})
 
 	Describe("NewManifestV2Windows", func() {
@@ -40,4 +109,21 @@ var _ = Describe("ManifestV2", func() {
 			Expect(manifest).To(gomegamatchers.MatchYAML(consulManifest))
 		})
 	})
+
+	Describe("WriteManifestV2Windows", func() {
+		It("writes the consul manifest using a windows client into the writer", func() {
+			consulManifest, err := ioutil.ReadFile("fixtures/consul_manifest_v2_windows.yml")
+			Expect(err).NotTo(HaveOccurred())
+
+			buffer := &bytes.Buffer{}
+			err = consul.WriteManifestV2Windows(buffer, consul.ConfigV2{
+				Name: "some-manifest-name",
+				AZs:  []string{"z1", "z2"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(HavePrefix("name: some-manifest-name\n"))
+			Expect(buffer.String()).To(gomegamatchers.MatchYAML(consulManifest))
+		})
+	})
 })
@@ -1,26 +1,69 @@
 package etcd
 
-import "github.com/pivotal-cf-experimental/destiny/ops"
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+)
+
+var (
+	manifestV2TLSTemplate    = ops.NewTemplate(manifestV2TLS)
+	manifestV2NonTLSTemplate = ops.NewTemplate(manifestV2NonTLS)
+)
 
 type ConfigV2 struct {
 	Name      string
 	AZs       []string
 	EnableSSL bool
+	Format    ops.OutputFormat
 }
 
 func NewManifestV2(config ConfigV2) (string, error) {
+	buffer := &bytes.Buffer{}
+	err := WriteManifestV2(buffer, config)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+func WriteManifestV2(w io.Writer, config ConfigV2) error {
+	document, err := newManifestV2(config)
+	if err != nil {
+		return err
+	}
+
+	err = document.Validate()
+	if err != nil {
+		return err
+	}
+
+	return ops.Renderer{Format: config.Format, Conventional: true}.WriteDocument(w, document)
+}
+
+func newManifestV2(config ConfigV2) (ops.Document, error) {
 	if config.EnableSSL {
-		return ops.ApplyOps(manifestV2TLS, []ops.Op{
-			{"replace", "/name", config.Name},
-			{"replace", "/instance_groups/name=consul/azs", config.AZs},
-			{"replace", "/instance_groups/name=etcd/azs", config.AZs},
-			{"replace", "/instance_groups/name=testconsumer/azs", config.AZs},
+		document, err := manifestV2TLSTemplate.Document()
+		if err != nil {
+			return ops.Document{}, err
+		}
+
+		return document.ApplyOps([]ops.Op{
+			{Type: "replace", Path: "/name", Value: config.Name},
+			{Type: "replace", Path: "/instance_groups/*/azs", Value: config.AZs},
 		})
 	}
 
-	return ops.ApplyOps(manifestV2NonTLS, []ops.Op{
-		{"replace", "/name", config.Name},
-		{"replace", "/instance_groups/name=etcd/azs", config.AZs},
-		{"replace", "/instance_groups/name=testconsumer/azs", config.AZs},
+	document, err := manifestV2NonTLSTemplate.Document()
+	if err != nil {
+		return ops.Document{}, err
+	}
+
+	return document.ApplyOps([]ops.Op{
+		{Type: "replace", Path: "/name", Value: config.Name},
+		{Type: "replace", Path: "/instance_groups/*/azs", Value: config.AZs},
 	})
 }
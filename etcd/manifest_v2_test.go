@@ -1,9 +1,11 @@
 package etcd_test
 
 import (
+	"bytes"
 	"io/ioutil"
 
 	"github.com/pivotal-cf-experimental/destiny/etcd"
+	"github.com/pivotal-cf-experimental/destiny/ops"
 	"github.com/pivotal-cf-experimental/gomegamatchers"
 
 	. "github.com/onsi/ginkgo"
@@ -43,5 +45,61 @@ var _ = Describe("ManifestV2", func() {
 				Expect(manifest).To(gomegamatchers.MatchYAML(etcdManifest))
 			})
 		})
+
+		It("writes the manifest in the conventional BOSH key order", func() {
+			manifest, err := etcd.NewManifestV2(etcd.ConfigV2{
+				Name: "some-manifest-name",
+				AZs:  []string{"z1"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifest).To(HavePrefix("name: some-manifest-name\nreleases:\n"))
+			Expect(manifest).To(ContainSubstring("instance_groups:\n- name: etcd\n  azs:\n  - z1\n  instances: 3\n"))
+		})
+
+		It("returns a JSON representation when asked to", func() {
+			etcdManifest, err := ioutil.ReadFile("fixtures/etcd_manifest_v2_non_tls.yml")
+			Expect(err).NotTo(HaveOccurred())
+
+			manifest, err := etcd.NewManifestV2(etcd.ConfigV2{
+				Name:   "some-manifest-name",
+				AZs:    []string{"z1", "z2"},
+				Format: ops.CompactJSONOutput,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifest).To(HavePrefix(`{"instance_groups":[{`))
+			Expect(manifest).To(gomegamatchers.MatchYAML(etcdManifest))
+		})
+
+		Context("failure cases", func() {
+			Context("when no azs are provided", func() {
+				It("returns a validation error", func() {
+					_, err := etcd.NewManifestV2(etcd.ConfigV2{
+						Name: "some-manifest-name",
+					})
+					Expect(err).To(MatchError(ContainSubstring("/instance_groups/name=etcd/azs: azs must not be empty")))
+				})
+			})
+		})
+	})
+
+	Describe("WriteManifestV2", func() {
+		It("writes the etcd manifest into the writer", func() {
+			etcdManifest, err := ioutil.ReadFile("fixtures/etcd_manifest_v2_tls.yml")
+			Expect(err).NotTo(HaveOccurred())
+
+			buffer := &bytes.Buffer{}
+			err = etcd.WriteManifestV2(buffer, etcd.ConfigV2{
+				Name:      "some-manifest-name",
+				AZs:       []string{"z1", "z2"},
+				EnableSSL: true,
+				Format:    ops.JSONOutput,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(HaveSuffix("}\n"))
+			Expect(buffer.String()).To(gomegamatchers.MatchYAML(etcdManifest))
+		})
 	})
 })
@@ -0,0 +1,206 @@
+package ops
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Changed ChangeKind = "changed"
+	Removed ChangeKind = "removed"
+)
+
+// Change is a single value that an op added, changed or removed. Items of
+// arrays whose items all have a unique name are addressed with name=
+// selectors. Overwrites lists the earlier ops that had changed the same path,
+// or a path above or below it, before this op changed it again.
+type Change struct {
+	Path       string
+	Kind       ChangeKind
+	OldValue   interface{}
+	NewValue   interface{}
+	OpIndex    int
+	Overwrites []int
+}
+
+func (c Change) String() string {
+	description := ""
+	switch c.Kind {
+	case Added:
+		description = fmt.Sprintf("added '%s' as '%v'", c.Path, c.NewValue)
+	case Removed:
+		description = fmt.Sprintf("removed '%s' (was '%v')", c.Path, c.OldValue)
+	default:
+		description = fmt.Sprintf("changed '%s' from '%v' to '%v'", c.Path, c.OldValue, c.NewValue)
+	}
+
+	if len(c.Overwrites) > 0 {
+		overwrites := []string{}
+		for _, index := range c.Overwrites {
+			overwrites = append(overwrites, fmt.Sprint(index))
+		}
+
+		description += fmt.Sprintf(", overwriting op %s", strings.Join(overwrites, ", "))
+	}
+
+	return fmt.Sprintf("op %d %s", c.OpIndex, description)
+}
+
+// changeTracker remembers which op last changed each path, so that a later
+// change can tell which earlier ops it overwrote.
+type changeTracker struct {
+	lastChangedBy map[string]int
+}
+
+func newChangeTracker() changeTracker {
+	return changeTracker{
+		lastChangedBy: map[string]int{},
+	}
+}
+
+func (t changeTracker) changes(opIndex int, before, after interface{}) []Change {
+	changes := collectChanges(NewPath(), before, after)
+
+	for i := range changes {
+		changes[i].OpIndex = opIndex
+		changes[i].Overwrites = t.overwrites(opIndex, changes[i].Path)
+	}
+
+	for _, change := range changes {
+		for changedPath := range t.lastChangedBy {
+			if strings.HasPrefix(changedPath, change.Path+"/") {
+				delete(t.lastChangedBy, changedPath)
+			}
+		}
+
+		t.lastChangedBy[change.Path] = opIndex
+	}
+
+	return changes
+}
+
+func (t changeTracker) overwrites(opIndex int, path string) []int {
+	seen := map[int]bool{}
+	overwrites := []int{}
+
+	for changedPath, index := range t.lastChangedBy {
+		if index == opIndex || seen[index] || !pathsOverlap(path, changedPath) {
+			continue
+		}
+
+		seen[index] = true
+		overwrites = append(overwrites, index)
+	}
+
+	sort.Ints(overwrites)
+	return overwrites
+}
+
+func pathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+func collectChanges(path Path, before, after interface{}) []Change {
+	if reflect.DeepEqual(before, after) {
+		return []Change{}
+	}
+
+	switch typedBefore := before.(type) {
+	case map[interface{}]interface{}:
+		if typedAfter, ok := after.(map[interface{}]interface{}); ok {
+			return collectMapChanges(path, typedBefore, typedAfter)
+		}
+	case []interface{}:
+		if typedAfter, ok := after.([]interface{}); ok {
+			return collectArrayChanges(path, typedBefore, typedAfter)
+		}
+	}
+
+	return []Change{{Path: path.String(), Kind: Changed, OldValue: before, NewValue: after}}
+}
+
+func collectMapChanges(path Path, before, after map[interface{}]interface{}) []Change {
+	changes := []Change{}
+
+	keys := map[interface{}]interface{}{}
+	for key := range before {
+		keys[key] = nil
+	}
+	for key := range after {
+		keys[key] = nil
+	}
+
+	for _, key := range sortedMapKeys(keys) {
+		keyPath := path.Key(fmt.Sprint(key))
+
+		beforeValue, inBefore := before[key]
+		afterValue, inAfter := after[key]
+
+		switch {
+		case !inAfter:
+			changes = append(changes, Change{Path: keyPath.String(), Kind: Removed, OldValue: beforeValue})
+		case !inBefore:
+			changes = append(changes, Change{Path: keyPath.String(), Kind: Added, NewValue: afterValue})
+		default:
+			changes = append(changes, collectChanges(keyPath, beforeValue, afterValue)...)
+		}
+	}
+
+	return changes
+}
+
+func collectArrayChanges(path Path, before, after []interface{}) []Change {
+	changes := []Change{}
+
+	beforeNames, beforeNamed := arrayNames(before)
+	afterNames, afterNamed := arrayNames(after)
+
+	if beforeNamed && afterNamed {
+		afterIndexes := map[string]int{}
+		for i, name := range afterNames {
+			afterIndexes[name] = i
+		}
+
+		beforeIndexes := map[string]int{}
+		for i, name := range beforeNames {
+			beforeIndexes[name] = i
+
+			itemPath := path.Name(name)
+			afterIndex, ok := afterIndexes[name]
+			if !ok {
+				changes = append(changes, Change{Path: itemPath.String(), Kind: Removed, OldValue: before[i]})
+				continue
+			}
+
+			changes = append(changes, collectChanges(itemPath, before[i], after[afterIndex])...)
+		}
+
+		for i, name := range afterNames {
+			if _, ok := beforeIndexes[name]; !ok {
+				changes = append(changes, Change{Path: path.Name(name).String(), Kind: Added, NewValue: after[i]})
+			}
+		}
+
+		return changes
+	}
+
+	for i := 0; i < len(before) || i < len(after); i++ {
+		itemPath := path.Index(i)
+
+		switch {
+		case i >= len(after):
+			changes = append(changes, Change{Path: itemPath.String(), Kind: Removed, OldValue: before[i]})
+		case i >= len(before):
+			changes = append(changes, Change{Path: itemPath.String(), Kind: Added, NewValue: after[i]})
+		default:
+			changes = append(changes, collectChanges(itemPath, before[i], after[i])...)
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,80 @@
+package ops_test
+
+import (
+	"github.com/pivotal-cf-experimental/destiny/ops"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Changes", func() {
+	var manifest string
+
+	BeforeEach(func() {
+		manifest = `name: some-name
+instance_groups:
+- name: consul
+  azs: [z1]
+  instances: 3
+- name: testconsumer
+  azs: [z1]
+  instances: 1`
+	})
+
+	It("reports what each op added, changed and removed", func() {
+		_, report, err := ops.ApplyOpsWithReport(manifest, []ops.Op{
+			{Type: "replace", Path: "/name", Value: "some-other-name"},
+			{Type: "replace", Path: "/instance_groups/name=consul/vm_type?", Value: "default"},
+			{Type: "remove", Path: "/instance_groups/name=testconsumer"},
+			{Type: "replace", Path: "/instance_groups/name=consul/azs/-", Value: "z2"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.Changes).To(Equal([]ops.Change{
+			{Path: "/name", Kind: ops.Changed, OldValue: "some-name", NewValue: "some-other-name", OpIndex: 0, Overwrites: []int{}},
+			{Path: "/instance_groups/name=consul/vm_type", Kind: ops.Added, NewValue: "default", OpIndex: 1, Overwrites: []int{}},
+			{
+				Path:       "/instance_groups/name=testconsumer",
+				Kind:       ops.Removed,
+				OldValue:   map[interface{}]interface{}{"name": "testconsumer", "azs": []interface{}{"z1"}, "instances": 1},
+				OpIndex:    2,
+				Overwrites: []int{},
+			},
+			{Path: "/instance_groups/name=consul/azs/1", Kind: ops.Added, NewValue: "z2", OpIndex: 3, Overwrites: []int{}},
+		}))
+	})
+
+	It("shows when a later op overwrote an earlier one", func() {
+		_, report, err := ops.ApplyOpsWithReport(manifest, []ops.Op{
+			{Type: "replace", Path: "/instance_groups/*/azs", Value: []string{"z2"}},
+			{Type: "replace", Path: "/instance_groups/name=consul/instances", Value: 5},
+			{Type: "replace", Path: "/instance_groups/name=consul/azs/0", Value: "z3"},
+			{Type: "replace", Path: "/instance_groups/name=consul", Value: map[string]interface{}{"name": "consul", "azs": []string{"z4"}}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		summary := []string{}
+		for _, change := range report.Changes {
+			summary = append(summary, change.String())
+		}
+
+		Expect(summary).To(Equal([]string{
+			"op 0 changed '/instance_groups/name=consul/azs/0' from 'z1' to 'z2'",
+			"op 0 changed '/instance_groups/name=testconsumer/azs/0' from 'z1' to 'z2'",
+			"op 1 changed '/instance_groups/name=consul/instances' from '3' to '5'",
+			"op 2 changed '/instance_groups/name=consul/azs/0' from 'z2' to 'z3', overwriting op 0",
+			"op 3 changed '/instance_groups/name=consul/azs/0' from 'z3' to 'z4', overwriting op 2",
+			"op 3 removed '/instance_groups/name=consul/instances' (was '5'), overwriting op 1",
+		}))
+	})
+
+	It("does not report changes for skipped ops", func() {
+		_, report, err := ops.ApplyOpsWithReport(manifest, []ops.Op{
+			{Type: "remove", Path: "/name", If: &ops.Condition{Path: "/director_uuid"}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.Changes).To(BeEmpty())
+		Expect(report.Skipped).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,114 @@
+package ops
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/cppforlife/go-patch/patch"
+)
+
+// Condition guards an op so that it is only applied when the condition holds.
+// With Absent set the condition holds when nothing exists at Path, with a
+// Value it holds when the value at Path equals Value, and otherwise it holds
+// when anything exists at Path.
+type Condition struct {
+	Path   string      `yaml:"path"`
+	Value  interface{} `yaml:"value,omitempty"`
+	Absent bool        `yaml:"absent,omitempty"`
+
+	pathErr error
+}
+
+// IfExists returns a copy of the op that is only applied when path exists.
+func (o Op) IfExists(path Path) Op {
+	o.If = &Condition{Path: path.String(), pathErr: path.Validate()}
+	return o
+}
+
+// IfAbsent returns a copy of the op that is only applied when path is absent.
+func (o Op) IfAbsent(path Path) Op {
+	o.If = &Condition{Path: path.String(), Absent: true, pathErr: path.Validate()}
+	return o
+}
+
+// IfEquals returns a copy of the op that is only applied when the value at
+// path equals value.
+func (o Op) IfEquals(path Path, value interface{}) Op {
+	o.If = &Condition{Path: path.String(), Value: value, pathErr: path.Validate()}
+	return o
+}
+
+// SkippedOp is an op that was not applied because its condition did not
+// hold. Index is the position of the op in the list that was given.
+type SkippedOp struct {
+	Index  int
+	Op     Op
+	Reason string
+}
+
+func (s SkippedOp) String() string {
+	return fmt.Sprintf("skipped op %d (%s '%s'): %s", s.Index, s.Op.Type, s.Op.Path, s.Reason)
+}
+
+type ApplyReport struct {
+	Skipped []SkippedOp
+	Changes []Change
+}
+
+// Summary lists the skipped ops one per line.
+func (r ApplyReport) Summary() string {
+	lines := []string{}
+	for _, skipped := range r.Skipped {
+		lines = append(lines, skipped.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// holds reports whether the condition holds for doc, and if it does not, the
+// reason why.
+func (c Condition) holds(doc interface{}) (bool, string, error) {
+	pointer, err := patch.NewPointerFromString(c.Path)
+	if err != nil {
+		return false, "", err
+	}
+
+	found, err := patch.FindOp{Path: pointer}.Apply(doc)
+	exists := err == nil && !(found == nil && isOptional(pointer))
+	if err != nil {
+		switch opErrorCause(err) {
+		case MissingKey, MissingNameMatch, IndexOutOfRange:
+		default:
+			return false, "", err
+		}
+	}
+
+	if c.Absent {
+		if exists {
+			return false, fmt.Sprintf("'%s' exists", c.Path), nil
+		}
+
+		return true, "", nil
+	}
+
+	if !exists {
+		return false, fmt.Sprintf("'%s' does not exist", c.Path), nil
+	}
+
+	if c.Value == nil {
+		return true, "", nil
+	}
+
+	value, err := normalizeValue(c.Value)
+	if err != nil {
+		// not tested
+		return false, "", err
+	}
+
+	if !reflect.DeepEqual(found, value) {
+		return false, fmt.Sprintf("'%s' is '%v' instead of '%v'", c.Path, found, value), nil
+	}
+
+	return true, "", nil
+}
@@ -0,0 +1,110 @@
+package ops_test
+
+import (
+	"github.com/pivotal-cf-experimental/destiny/ops"
+	"github.com/pivotal-cf-experimental/gomegamatchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Conditions", func() {
+	var manifest string
+
+	BeforeEach(func() {
+		manifest = `name: some-name
+instance_groups:
+- name: testconsumer
+  jobs:
+  - name: consul_agent
+    release: consul`
+	})
+
+	Describe("ApplyOpsWithReport", func() {
+		It("applies the ops whose conditions hold and skips the others", func() {
+			testconsumer := ops.NewPath().Key("instance_groups").Name("testconsumer")
+			etcd := ops.NewPath().Key("instance_groups").Name("etcd")
+			consulAgent := testconsumer.Key("jobs").Name("consul_agent")
+
+			modifiedManifest, report, err := ops.ApplyOpsWithReport(manifest, []ops.Op{
+				ops.Replace(testconsumer.Key("vm_extensions").Optional(), []string{"50GB_ephemeral_disk"}).IfExists(testconsumer),
+				ops.Replace(etcd.Key("vm_extensions").Optional(), []string{"50GB_ephemeral_disk"}).IfExists(etcd),
+				ops.Replace(consulAgent.Key("properties").Optional(), map[string]interface{}{"enabled": true}).IfEquals(consulAgent.Key("release"), "consul"),
+				ops.Replace(consulAgent.Key("name"), "consul_agent_windows").IfEquals(consulAgent.Key("name"), "consul_agent"),
+				ops.Replace(testconsumer.Key("jobs").Append(), map[string]interface{}{"name": "consul_agent_windows"}).IfAbsent(testconsumer.Key("jobs").Name("consul_agent_windows")),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(gomegamatchers.MatchYAML(`name: some-name
+instance_groups:
+- name: testconsumer
+  vm_extensions: [50GB_ephemeral_disk]
+  jobs:
+  - name: consul_agent_windows
+    release: consul
+    properties:
+      enabled: true`))
+
+			Expect(report.Skipped).To(HaveLen(2))
+			Expect(report.Skipped[0].Index).To(Equal(1))
+			Expect(report.Skipped[1].Index).To(Equal(4))
+			Expect(report.Summary()).To(Equal(`skipped op 1 (replace '/instance_groups/name=etcd/vm_extensions?'): '/instance_groups/name=etcd' does not exist
+skipped op 4 (replace '/instance_groups/name=testconsumer/jobs/-'): '/instance_groups/name=testconsumer/jobs/name=consul_agent_windows' exists`))
+		})
+
+		It("skips ops whose value does not match", func() {
+			_, report, err := ops.ApplyOpsWithReport(manifest, []ops.Op{
+				{
+					Type:  "replace",
+					Path:  "/name",
+					Value: "some-other-name",
+					If:    &ops.Condition{Path: "/name", Value: "some-different-name"},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Summary()).To(Equal("skipped op 0 (replace '/name'): '/name' is 'some-name' instead of 'some-different-name'"))
+		})
+
+		It("reports nothing when every op is applied", func() {
+			_, report, err := ops.ApplyOpsWithReport(manifest, []ops.Op{
+				{Type: "replace", Path: "/name", Value: "some-other-name"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Skipped).To(BeEmpty())
+			Expect(report.Summary()).To(BeEmpty())
+		})
+
+		It("evaluates each condition against the ops applied before it", func() {
+			modifiedManifest, report, err := ops.ApplyOpsWithReport(manifest, []ops.Op{
+				{Type: "remove", Path: "/instance_groups/name=testconsumer"},
+				{Type: "replace", Path: "/name", Value: "some-other-name", If: &ops.Condition{Path: "/instance_groups/name=testconsumer"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(gomegamatchers.MatchYAML("name: some-name\ninstance_groups: []"))
+			Expect(report.Skipped).To(HaveLen(1))
+		})
+
+		Context("failure cases", func() {
+			Context("when the condition path is bad", func() {
+				It("returns an error", func() {
+					_, _, err := ops.ApplyOpsWithReport(manifest, []ops.Op{
+						{Type: "replace", Path: "/name", Value: "some-other-name", If: &ops.Condition{Path: "name"}},
+					})
+					Expect(err).To(MatchError("op 0 (replace '/name') failed: invalid condition path 'name': Expected to start with '/'"))
+				})
+			})
+
+			Context("when the condition path goes through a scalar", func() {
+				It("returns an error", func() {
+					_, _, err := ops.ApplyOpsWithReport(manifest, []ops.Op{
+						{Type: "replace", Path: "/name", Value: "some-other-name", If: &ops.Condition{Path: "/name/0"}},
+					})
+					Expect(err).To(HaveOccurred())
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,266 @@
+package ops
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Diff returns the replace and remove ops that turn the from manifest into the
+// to manifest. Array items that all have a unique name are addressed with
+// name= selectors so that the ops can be re-applied to manifests where the
+// items have moved.
+func Diff(from, to string) ([]Op, error) {
+	var fromDoc, toDoc interface{}
+
+	err := yaml.Unmarshal([]byte(from), &fromDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal([]byte(to), &toDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffValues(NewPath(), fromDoc, toDoc), nil
+}
+
+func diffValues(path Path, from, to interface{}) []Op {
+	if reflect.DeepEqual(from, to) {
+		return []Op{}
+	}
+
+	switch typedFrom := from.(type) {
+	case map[interface{}]interface{}:
+		if typedTo, ok := to.(map[interface{}]interface{}); ok {
+			return diffMaps(path, typedFrom, typedTo)
+		}
+	case []interface{}:
+		if typedTo, ok := to.([]interface{}); ok {
+			return diffArrays(path, typedFrom, typedTo)
+		}
+	}
+
+	return []Op{{Type: "replace", Path: path.String(), Value: to}}
+}
+
+// diffMaps replaces the whole map when a key that changed cannot be written
+// as a path segment, such as '8080', which would read as an array index.
+func diffMaps(path Path, from, to map[interface{}]interface{}) []Op {
+	ops := []Op{}
+
+	for _, key := range sortedMapKeys(from) {
+		if _, ok := to[key]; !ok {
+			keyPath, ok := mapKeyPath(path, key)
+			if !ok {
+				return []Op{{Type: "replace", Path: path.String(), Value: to}}
+			}
+
+			ops = append(ops, Op{Type: "remove", Path: keyPath.String()})
+		}
+	}
+
+	for _, key := range sortedMapKeys(to) {
+		fromValue, inFrom := from[key]
+		if inFrom && reflect.DeepEqual(fromValue, to[key]) {
+			continue
+		}
+
+		keyPath, ok := mapKeyPath(path, key)
+		if !ok {
+			return []Op{{Type: "replace", Path: path.String(), Value: to}}
+		}
+
+		if !inFrom {
+			ops = append(ops, Op{Type: "replace", Path: keyPath.Optional().String(), Value: to[key]})
+			continue
+		}
+
+		ops = append(ops, diffValues(keyPath, fromValue, to[key])...)
+	}
+
+	return ops
+}
+
+func mapKeyPath(path Path, key interface{}) (Path, bool) {
+	typedKey, ok := key.(string)
+	if !ok {
+		return Path{}, false
+	}
+
+	keyPath := path.Key(typedKey)
+	return keyPath, keyPath.Validate() == nil
+}
+
+func diffArrays(path Path, from, to []interface{}) []Op {
+	fromNames, fromNamed := arrayNames(from)
+	toNames, toNamed := arrayNames(to)
+
+	if fromNamed && toNamed && namesRepresentable(path, fromNames) && namesRepresentable(path, toNames) {
+		if ops, ok := diffNamedArrays(path, from, to, fromNames, toNames); ok {
+			return ops
+		}
+
+		if ops, ok := diffRenamedArrays(path, from, to, fromNames, toNames); ok {
+			return ops
+		}
+	}
+
+	if len(to) >= len(from) && reflect.DeepEqual(from, to[:len(from)]) {
+		ops := []Op{}
+		for _, item := range to[len(from):] {
+			ops = append(ops, Op{Type: "replace", Path: path.Append().String(), Value: item})
+		}
+
+		return ops
+	}
+
+	if len(from) > len(to) && reflect.DeepEqual(from[:len(to)], to) {
+		ops := []Op{}
+		for i := len(from) - 1; i >= len(to); i-- {
+			ops = append(ops, Op{Type: "remove", Path: path.Index(i).String()})
+		}
+
+		return ops
+	}
+
+	if len(from) == len(to) {
+		ops := []Op{}
+		for i := range from {
+			ops = append(ops, diffValues(path.Index(i), from[i], to[i])...)
+		}
+
+		return ops
+	}
+
+	return []Op{{Type: "replace", Path: path.String(), Value: to}}
+}
+
+func namesRepresentable(path Path, names []string) bool {
+	for _, name := range names {
+		if path.Name(name).Validate() != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// diffNamedArrays only succeeds when the surviving items keep their relative
+// order and every new item comes after them, because appending is the only
+// way to add an item without addressing it by index.
+func diffNamedArrays(path Path, from, to []interface{}, fromNames, toNames []string) ([]Op, bool) {
+	toIndexes := map[string]int{}
+	for i, name := range toNames {
+		toIndexes[name] = i
+	}
+
+	fromIndexes := map[string]int{}
+	for i, name := range fromNames {
+		fromIndexes[name] = i
+	}
+
+	ops := []Op{}
+	kept := []string{}
+	for _, name := range fromNames {
+		if _, ok := toIndexes[name]; !ok {
+			ops = append(ops, Op{Type: "remove", Path: path.Name(name).String()})
+			continue
+		}
+
+		kept = append(kept, name)
+	}
+
+	for i, name := range kept {
+		if toNames[i] != name {
+			return nil, false
+		}
+	}
+
+	for _, name := range kept {
+		ops = append(ops, diffValues(path.Name(name), from[fromIndexes[name]], to[toIndexes[name]])...)
+	}
+
+	for _, item := range to[len(kept):] {
+		ops = append(ops, Op{Type: "replace", Path: path.Append().String(), Value: item})
+	}
+
+	return ops, true
+}
+
+// diffRenamedArrays handles arrays whose items stay in place but may be
+// renamed. Each item is still addressed by its original name, so the rename
+// has to be the last op for that item.
+func diffRenamedArrays(path Path, from, to []interface{}, fromNames, toNames []string) ([]Op, bool) {
+	if len(from) != len(to) {
+		return nil, false
+	}
+
+	fromIndexes := map[string]int{}
+	for i, name := range fromNames {
+		fromIndexes[name] = i
+	}
+
+	for i, name := range toNames {
+		if index, ok := fromIndexes[name]; ok && index != i {
+			return nil, false
+		}
+	}
+
+	ops := []Op{}
+	for i, name := range fromNames {
+		itemPath := path.Name(name)
+
+		var renameOps []Op
+		for _, op := range diffValues(itemPath, from[i], to[i]) {
+			if op.Path == itemPath.Key("name").String() {
+				renameOps = append(renameOps, op)
+				continue
+			}
+
+			ops = append(ops, op)
+		}
+
+		ops = append(ops, renameOps...)
+	}
+
+	return ops, true
+}
+
+func arrayNames(array []interface{}) ([]string, bool) {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, item := range array {
+		typedItem, ok := item.(map[interface{}]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		name, ok := typedItem["name"].(string)
+		if !ok || seen[name] {
+			return nil, false
+		}
+
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	return names, true
+}
+
+func sortedMapKeys(m map[interface{}]interface{}) []interface{} {
+	keys := []interface{}{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+
+	return keys
+}
@@ -0,0 +1,163 @@
+package ops_test
+
+import (
+	"io/ioutil"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+	"github.com/pivotal-cf-experimental/gomegamatchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff", func() {
+	It("returns the ops that turn one manifest into another", func() {
+		diffOps, err := ops.Diff(`
+name: some-name
+stemcells:
+- alias: default
+  os: ubuntu-trusty
+instance_groups:
+- name: consul
+  instances: 1
+  azs: [z1]
+  properties:
+    consul/agent:
+      domain: cf.internal
+- name: etcd
+  instances: 3
+- name: testconsumer
+  instances: 1
+  jobs:
+  - name: consul_agent
+    release: consul
+  - name: consul-test-consumer`, `
+name: some-other-name
+stemcells:
+- alias: default
+  os: ubuntu-trusty
+- alias: windows
+  os: windows2012R2
+instance_groups:
+- name: consul
+  instances: 3
+  azs: [z1, z2]
+  properties:
+    consul/agent:
+      domain: other.internal
+- name: testconsumer
+  instances: 1
+  vm_extensions: [50GB_ephemeral_disk]
+  jobs:
+  - name: consul_agent_windows
+    release: consul-windows
+  - name: consul-test-consumer
+- name: some-errand
+  lifecycle: errand`)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(diffOps).To(Equal([]ops.Op{
+			{Type: "remove", Path: "/instance_groups/name=etcd"},
+			{Type: "replace", Path: "/instance_groups/name=consul/azs/-", Value: "z2"},
+			{Type: "replace", Path: "/instance_groups/name=consul/instances", Value: 3},
+			{Type: "replace", Path: "/instance_groups/name=consul/properties/consul~1agent/domain", Value: "other.internal"},
+			{Type: "replace", Path: "/instance_groups/name=testconsumer/jobs/name=consul_agent/release", Value: "consul-windows"},
+			{Type: "replace", Path: "/instance_groups/name=testconsumer/jobs/name=consul_agent/name", Value: "consul_agent_windows"},
+			{Type: "replace", Path: "/instance_groups/name=testconsumer/vm_extensions?", Value: []interface{}{"50GB_ephemeral_disk"}},
+			{Type: "replace", Path: "/instance_groups/-", Value: map[interface{}]interface{}{
+				"name":      "some-errand",
+				"lifecycle": "errand",
+			}},
+			{Type: "replace", Path: "/name", Value: "some-other-name"},
+			{Type: "replace", Path: "/stemcells/-", Value: map[interface{}]interface{}{
+				"alias": "windows",
+				"os":    "windows2012R2",
+			}},
+		}))
+	})
+
+	It("returns no ops for equivalent manifests", func() {
+		diffOps, err := ops.Diff("name: some-name\nazs: [z1]", "azs: [z1]\nname: some-name")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(diffOps).To(BeEmpty())
+	})
+
+	It("removes items dropped from the end of an unnamed array", func() {
+		diffOps, err := ops.Diff("azs: [z1, z2, z3]", "azs: [z1]")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(diffOps).To(Equal([]ops.Op{
+			{Type: "remove", Path: "/azs/2"},
+			{Type: "remove", Path: "/azs/1"},
+		}))
+	})
+
+	It("escapes keys that contain a ':'", func() {
+		diffOps, err := ops.Diff("props:\n  a:b: 1", "props:\n  a:b: 2")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(diffOps).To(Equal([]ops.Op{
+			{Type: "replace", Path: "/props/a~7b", Value: 2},
+		}))
+
+		manifest, err := ops.ApplyOps("props:\n  a:b: 1", diffOps)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest).To(gomegamatchers.MatchYAML("props:\n  a:b: 2"))
+	})
+
+	It("replaces the parent when a changed key cannot be written as a path", func() {
+		for _, key := range []string{"'8080'", "a=b", "x?", "8080"} {
+			from := "props:\n  other: 1\n  " + key + ": 1"
+			to := "props:\n  other: 1\n  " + key + ": 2"
+
+			diffOps, err := ops.Diff(from, to)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diffOps).To(HaveLen(1), key)
+			Expect(diffOps[0].Path).To(Equal("/props"), key)
+
+			manifest, err := ops.ApplyOps(from, diffOps)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest).To(gomegamatchers.MatchYAML(to), key)
+		}
+	})
+
+	It("returns ops that reproduce the target when applied", func() {
+		for _, fixtures := range [][]string{
+			{"../consul/fixtures/consul_manifest_v2.yml", "../consul/fixtures/consul_manifest_v2_windows.yml"},
+			{"../consul/fixtures/consul_manifest_v2_windows.yml", "../consul/fixtures/consul_manifest_v2.yml"},
+			{"../etcd/fixtures/etcd_manifest_v2_non_tls.yml", "../etcd/fixtures/etcd_manifest_v2_tls.yml"},
+			{"../etcd/fixtures/etcd_manifest_v2_tls.yml", "../turbulence/fixtures/turbulence_manifest_v2.yml"},
+		} {
+			from, err := ioutil.ReadFile(fixtures[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			to, err := ioutil.ReadFile(fixtures[1])
+			Expect(err).NotTo(HaveOccurred())
+
+			diffOps, err := ops.Diff(string(from), string(to))
+			Expect(err).NotTo(HaveOccurred())
+
+			manifest, err := ops.ApplyOps(string(from), diffOps)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifest).To(gomegamatchers.MatchYAML(to), fixtures[1])
+		}
+	})
+
+	Context("failure cases", func() {
+		Context("when the from manifest yaml is invalid", func() {
+			It("returns an error", func() {
+				_, err := ops.Diff("%%%", "name: some-name")
+				Expect(err).To(MatchError("yaml: could not find expected directive name"))
+			})
+		})
+
+		Context("when the to manifest yaml is invalid", func() {
+			It("returns an error", func() {
+				_, err := ops.Diff("name: some-name", "%%%")
+				Expect(err).To(MatchError("yaml: could not find expected directive name"))
+			})
+		})
+	})
+})
@@ -0,0 +1,424 @@
+package ops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/cppforlife/go-patch/patch"
+
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Document is a manifest that has been parsed once. Ops, finds and retrievers
+// work directly on the parsed tree and the manifest is only serialized when
+// it is rendered. A Document is never modified in place, applying ops returns
+// a new Document, so a single parsed template can be shared between
+// goroutines.
+type Document struct {
+	source   string
+	tree     *sourceTree
+	original interface{}
+	doc      interface{}
+}
+
+// ParseDocument parses a manifest that holds a single YAML document or a JSON
+// object. A stream of several documents is rejected rather than silently
+// reduced to its first document, ParseStream has to be used for those.
+func ParseDocument(manifest string) (Document, error) {
+	sources, _ := splitDocuments(manifest)
+	if len(sources) > 1 {
+		return Document{}, fmt.Errorf("manifest contains %d documents, use ParseStream to select one", len(sources))
+	}
+
+	return parseDocument(manifest)
+}
+
+func parseDocument(manifest string) (Document, error) {
+	if !isJSON(manifest) {
+		return parseYAMLDocument(manifest)
+	}
+
+	doc, err := parseJSON(manifest)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			return Document{}, err
+		}
+
+		document, yamlErr := parseYAMLDocument(manifest)
+		if yamlErr != nil {
+			return Document{}, err
+		}
+
+		return document, nil
+	}
+
+	return Document{
+		source:   manifest,
+		original: doc,
+		doc:      doc,
+	}, nil
+}
+
+func parseYAMLDocument(manifest string) (Document, error) {
+	var doc interface{}
+	err := yaml.Unmarshal([]byte(manifest), &doc)
+	if err != nil {
+		return Document{}, err
+	}
+
+	return Document{
+		source:   manifest,
+		tree:     newSourceTree(manifest),
+		original: doc,
+		doc:      doc,
+	}, nil
+}
+
+func (d Document) ApplyOp(op Op) (Document, error) {
+	return d.ApplyOps([]Op{op})
+}
+
+// ApplyOps applies ops in order. An op whose path has a "*" segment is
+// applied to every match in the document as it is when that op is reached.
+func (d Document) ApplyOps(ops []Op) (Document, error) {
+	document, _, err := d.apply(ops, false)
+	return document, err
+}
+
+// ApplyOpsWithReport applies ops like ApplyOps and also reports the ops that
+// were skipped because their condition did not hold, and every change that
+// each op made.
+func (d Document) ApplyOpsWithReport(ops []Op) (Document, ApplyReport, error) {
+	return d.apply(ops, true)
+}
+
+func (d Document) apply(ops []Op, trackChanges bool) (Document, ApplyReport, error) {
+	report := ApplyReport{
+		Skipped: []SkippedOp{},
+		Changes: []Change{},
+	}
+	tracker := newChangeTracker()
+
+	for i, op := range ops {
+		if op.pathErr != nil {
+			return Document{}, ApplyReport{}, newOpError(i, op, BadPointer, op.pathErr)
+		}
+
+		_, err := makeGoPatchOp(op)
+		if err != nil {
+			cause := UnknownCause
+			if _, pointerErr := patch.NewPointerFromString(op.Path); pointerErr != nil {
+				cause = BadPointer
+			}
+
+			return Document{}, ApplyReport{}, newOpError(i, op, cause, err)
+		}
+
+		if op.If != nil {
+			_, err := patch.NewPointerFromString(op.If.Path)
+			if err == nil {
+				err = op.If.pathErr
+			}
+
+			if err != nil {
+				return Document{}, ApplyReport{}, newOpError(i, op, BadPointer, fmt.Errorf("invalid condition path '%s': %s", op.If.Path, err))
+			}
+		}
+	}
+
+	patchedDoc := copyValue(d.doc)
+	for i, op := range ops {
+		if op.If != nil {
+			holds, reason, err := op.If.holds(patchedDoc)
+			if err != nil {
+				return Document{}, ApplyReport{}, newOpError(i, op, opErrorCause(err), err)
+			}
+
+			if !holds {
+				report.Skipped = append(report.Skipped, SkippedOp{Index: i, Op: op, Reason: reason})
+				continue
+			}
+		}
+
+		var before interface{}
+		if trackChanges {
+			before = copyValue(patchedDoc)
+		}
+
+		expandedOps, err := expandOp(patchedDoc, op)
+		if err != nil {
+			opErr := newOpError(i, op, opErrorCause(err), err)
+			opErr.describe(d.source, d.original, patchedDoc)
+
+			return Document{}, ApplyReport{}, opErr
+		}
+
+		for _, expandedOp := range expandedOps {
+			goPatchOp, err := makeGoPatchOp(expandedOp)
+			if err != nil {
+				// not tested
+				return Document{}, ApplyReport{}, newOpError(i, op, UnknownCause, err)
+			}
+
+			nextDoc, err := goPatchOp.Apply(patchedDoc)
+			if err != nil {
+				opErr := newOpError(i, op, opErrorCause(err), err)
+				opErr.describe(d.source, d.original, patchedDoc)
+
+				return Document{}, ApplyReport{}, opErr
+			}
+
+			patchedDoc = nextDoc
+		}
+
+		if trackChanges {
+			report.Changes = append(report.Changes, tracker.changes(i, before, patchedDoc)...)
+		}
+	}
+
+	return Document{
+		source:   d.source,
+		tree:     d.tree,
+		original: d.original,
+		doc:      patchedDoc,
+	}, report, nil
+}
+
+func (d Document) Find(path string) (interface{}, error) {
+	pointerPath, err := patch.NewPointerFromString(path)
+	if err != nil {
+		return "", err
+	}
+
+	goPatchOps := patch.Ops{
+		patch.FindOp{
+			Path: pointerPath,
+		},
+	}
+
+	found, err := goPatchOps.Apply(d.doc)
+	if err != nil {
+		// not tested
+		return "", err
+	}
+
+	return copyValue(found), nil
+}
+
+func (d Document) Manifest() (Manifest, error) {
+	var manifest Manifest
+	err := d.decode(&manifest)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	return manifest, nil
+}
+
+func (d Document) Validate() error {
+	manifest, err := d.Manifest()
+	if err != nil {
+		return err
+	}
+
+	problems := validateManifest(manifest)
+	if len(problems) > 0 {
+		return ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+func (d Document) Marshal() (string, error) {
+	return Renderer{}.RenderDocument(d)
+}
+
+// decode converts the parsed tree into out. Only the top level keys that out
+// has fields for are converted, so a retriever does not fail because of
+// unrelated parts of the manifest. Unchanged scalars are decoded from the way
+// the source spelled them, so a string field gets "1.10" rather than "1.1".
+func (d Document) decode(out interface{}, keys ...string) error {
+	original, doc := d.original, d.doc
+	if len(keys) > 0 {
+		original, doc = topLevelSubset(d.original, keys), topLevelSubset(d.doc, keys)
+	}
+
+	node, err := valueNode(doc)
+	if err != nil {
+		// not tested
+		return err
+	}
+
+	if d.tree != nil {
+		root, err := d.tree.node()
+		if err != nil {
+			// not tested
+			return err
+		}
+
+		if root != nil {
+			restorer := scalarRestorer{keepTags: true}
+			restorer.restore(node, root, original, doc)
+		}
+	}
+
+	err = node.Decode(out)
+	if err != nil {
+		return err
+	}
+
+	useYAMLv2Maps(reflect.ValueOf(out))
+
+	return nil
+}
+
+// valueNode builds the node tree of a parsed value without serializing it.
+func valueNode(value interface{}) (*yamlv3.Node, error) {
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		mapping := &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map"}
+		for key, child := range typedValue {
+			keyNode, err := valueNode(key)
+			if err != nil {
+				return nil, err
+			}
+
+			childNode, err := valueNode(child)
+			if err != nil {
+				return nil, err
+			}
+
+			mapping.Content = append(mapping.Content, keyNode, childNode)
+		}
+
+		return mapping, nil
+	case []interface{}:
+		sequence := &yamlv3.Node{Kind: yamlv3.SequenceNode, Tag: "!!seq"}
+		for _, item := range typedValue {
+			itemNode, err := valueNode(item)
+			if err != nil {
+				return nil, err
+			}
+
+			sequence.Content = append(sequence.Content, itemNode)
+		}
+
+		return sequence, nil
+	case nil:
+		return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	case string:
+		return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: typedValue}, nil
+	case bool:
+		return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(typedValue)}, nil
+	case int:
+		return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!int", Value: strconv.Itoa(typedValue)}, nil
+	case int64:
+		return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(typedValue, 10)}, nil
+	case uint64:
+		return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!int", Value: strconv.FormatUint(typedValue, 10)}, nil
+	case float64:
+		return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(typedValue, 'g', -1, 64)}, nil
+	}
+
+	// not tested
+	node := &yamlv3.Node{}
+	err := node.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// useYAMLv2Maps replaces the maps that yaml.v3 decodes into interface{}
+// values with the map[interface{}]interface{} that yaml.v2 decodes them into,
+// which is what the rest of the package works with.
+func useYAMLv2Maps(value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if !value.IsNil() {
+			useYAMLv2Maps(value.Elem())
+		}
+	case reflect.Interface:
+		if !value.IsNil() && value.CanSet() {
+			value.Set(reflect.ValueOf(yamlv2Value(value.Interface())))
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if value.Field(i).CanSet() {
+				useYAMLv2Maps(value.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			useYAMLv2Maps(value.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			child := reflect.New(value.Type().Elem()).Elem()
+			child.Set(value.MapIndex(key))
+			useYAMLv2Maps(child)
+			value.SetMapIndex(key, child)
+		}
+	}
+}
+
+func yamlv2Value(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		converted := map[interface{}]interface{}{}
+		for key, child := range typedValue {
+			converted[key] = yamlv2Value(child)
+		}
+
+		return converted
+	case []interface{}:
+		for i, item := range typedValue {
+			typedValue[i] = yamlv2Value(item)
+		}
+
+		return typedValue
+	default:
+		return value
+	}
+}
+
+func topLevelSubset(doc interface{}, keys []string) interface{} {
+	subset := map[interface{}]interface{}{}
+	if typedDoc, ok := doc.(map[interface{}]interface{}); ok {
+		for _, key := range keys {
+			if value, ok := typedDoc[key]; ok {
+				subset[key] = value
+			}
+		}
+	}
+
+	return subset
+}
+
+// Template is a manifest template that is parsed the first time it is used.
+// The parsed Document is shared by every later caller.
+type Template struct {
+	manifest string
+	once     sync.Once
+	document Document
+	err      error
+}
+
+func NewTemplate(manifest string) *Template {
+	return &Template{manifest: manifest}
+}
+
+func (t *Template) Document() (Document, error) {
+	t.once.Do(func() {
+		t.document, t.err = ParseDocument(t.manifest)
+	})
+
+	return t.document, t.err
+}
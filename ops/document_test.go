@@ -0,0 +1,228 @@
+package ops_test
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+	"github.com/pivotal-cf-experimental/gomegamatchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Document", func() {
+	var manifest string
+
+	BeforeEach(func() {
+		manifest = `name: some-name # the deployment
+releases:
+- name: consul
+  version: latest
+stemcells:
+- alias: default
+  os: ubuntu-trusty
+  version: latest
+instance_groups:
+- name: consul
+  azs: [z1]
+  instances: 3
+  vm_type: default
+  stemcell: default
+  jobs:
+  - name: consul_agent
+    release: consul
+- name: test
+  azs: [z1]
+  instances: 1
+  lifecycle: errand
+  stemcell: default
+  jobs: []`
+	})
+
+	Describe("ApplyOps", func() {
+		It("returns a new document with the ops applied", func() {
+			document, err := ops.ParseDocument(manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			modifiedDocument, err := document.ApplyOps([]ops.Op{
+				{Type: "replace", Path: "/name", Value: "some-changed-name"},
+				{Type: "remove", Path: "/instance_groups/name=test"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			name, err := modifiedDocument.Name()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("some-changed-name"))
+
+			instanceGroups, err := modifiedDocument.InstanceGroups()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instanceGroups).To(HaveLen(1))
+
+			name, err = document.Name()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("some-name"))
+		})
+
+		It("can be applied from many goroutines at once", func() {
+			document, err := ops.ParseDocument(manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+
+					_, err := document.ApplyOps([]ops.Op{
+						{Type: "replace", Path: "/instance_groups/name=consul/azs/-", Value: "z2"},
+					})
+					Expect(err).NotTo(HaveOccurred())
+				}()
+			}
+			wg.Wait()
+
+			azs, err := document.AZs("consul")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(azs).To(Equal([]string{"z1"}))
+		})
+
+		Context("failure cases", func() {
+			Context("when the op type is not supported", func() {
+				It("returns an error", func() {
+					document, err := ops.ParseDocument(manifest)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = document.ApplyOp(ops.Op{Type: "invalid-op"})
+					Expect(err).To(MatchError("op 0 (invalid-op '') failed: op type invalid-op not supported by destiny"))
+				})
+			})
+		})
+	})
+
+	Describe("Find", func() {
+		It("returns a copy of the value at the path", func() {
+			document, err := ops.ParseDocument(manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			azs, err := document.Find("/instance_groups/name=consul/azs")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(azs).To(Equal([]interface{}{"z1"}))
+
+			azs.([]interface{})[0] = "z2"
+
+			azs, err = document.Find("/instance_groups/name=consul/azs")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(azs).To(Equal([]interface{}{"z1"}))
+		})
+
+		Context("failure cases", func() {
+			Context("when the path is bad", func() {
+				It("returns an error", func() {
+					document, err := ops.ParseDocument(manifest)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = document.Find("%%%")
+					Expect(err).To(MatchError("Expected to start with '/'"))
+				})
+			})
+		})
+	})
+
+	Describe("retrievers", func() {
+		It("retrieves values from the parsed tree", func() {
+			document, err := ops.ParseDocument(manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			errands, err := document.ErrandInstanceGroups()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errands).To(HaveLen(1))
+			Expect(errands[0].Name).To(Equal("test"))
+
+			releases, err := document.Releases()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(releases).To(Equal([]ops.Release{{Name: "consul", Version: "latest"}}))
+
+			jobs, err := document.Jobs("consul")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jobs).To(HaveLen(1))
+			Expect(jobs[0].Name).To(Equal("consul_agent"))
+
+			vmType, err := document.VMType("consul")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(vmType).To(Equal("default"))
+		})
+
+		It("does not fail because of unrelated parts of the manifest", func() {
+			document, err := ops.ParseDocument("name: some-name\nreleases: some-invalid-releases")
+			Expect(err).NotTo(HaveOccurred())
+
+			name, err := document.Name()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("some-name"))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("validates the parsed manifest", func() {
+			document, err := ops.ParseDocument(manifest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(document.Validate()).To(Succeed())
+
+			document, err = document.ApplyOp(ops.Op{Type: "replace", Path: "/instance_groups/name=consul/stemcell", Value: "windows"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(document.Validate()).To(MatchError(ContainSubstring("stemcell alias 'windows' is not declared under stemcells")))
+		})
+	})
+
+	Describe("Marshal", func() {
+		It("serializes the document", func() {
+			document, err := ops.ParseDocument(manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			manifestYAML, err := document.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifestYAML).To(gomegamatchers.MatchYAML(manifest))
+		})
+
+		It("can preserve the layout of the parsed manifest", func() {
+			document, err := ops.ParseDocument(manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			document, err = document.ApplyOp(ops.Op{Type: "replace", Path: "/name", Value: "some-changed-name"})
+			Expect(err).NotTo(HaveOccurred())
+
+			manifestYAML, err := ops.Renderer{Options: ops.ApplyOptions{PreserveLayout: true}}.RenderDocument(document)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifestYAML).To(Equal(strings.Replace(manifest, "some-name", "some-changed-name", 1)))
+		})
+	})
+
+	Describe("Template", func() {
+		It("parses the manifest once and shares the document", func() {
+			template := ops.NewTemplate(manifest)
+
+			document, err := template.Document()
+			Expect(err).NotTo(HaveOccurred())
+
+			name, err := document.Name()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("some-name"))
+		})
+
+		Context("failure cases", func() {
+			Context("when the template yaml is invalid", func() {
+				It("returns an error on every use", func() {
+					template := ops.NewTemplate("%%%")
+
+					_, err := template.Document()
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+
+					_, err = template.Document()
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,704 @@
+package ops
+
+import (
+	"bytes"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const emitterIndent = 2
+
+// layoutEmitter writes a yaml.v3 node tree out the way BOSH manifests are
+// written. The yaml.v3 encoder always indents block sequences below their key,
+// so the emitter writes the block structure itself: sequences stay at the
+// indentation of their key unless the source indented them, and blank lines in
+// front of entries are kept. Scalars and flow collections are copied from the
+// source text as long as that text still reads as the node, anything else is
+// written with the yaml.v3 encoder.
+type layoutEmitter struct {
+	lines [][]rune
+	out   *bytes.Buffer
+
+	// endsInBlockScalar is set when the last line written belongs to a block
+	// scalar, whose content would change if its final line break was dropped.
+	endsInBlockScalar bool
+}
+
+func newLayoutEmitter(source string) *layoutEmitter {
+	lines := [][]rune{}
+	for _, line := range strings.Split(strings.TrimSuffix(source, "\n"), "\n") {
+		lines = append(lines, []rune(line))
+	}
+
+	return &layoutEmitter{
+		lines: lines,
+		out:   &bytes.Buffer{},
+	}
+}
+
+func (e *layoutEmitter) emitDocument(document *yamlv3.Node) (string, error) {
+	e.emitComment(document.HeadComment, 0)
+
+	for _, node := range document.Content {
+		err := e.emitRoot(node)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	e.emitComment(document.FootComment, 0)
+
+	return e.out.String(), nil
+}
+
+func (e *layoutEmitter) emitRoot(node *yamlv3.Node) error {
+	switch {
+	case isBlockMapping(node):
+		e.emitComment(node.HeadComment, 0)
+
+		err := e.emitMapping(node, 0, false)
+		if err != nil {
+			return err
+		}
+
+		e.emitComment(node.FootComment, 0)
+	case isBlockSequence(node):
+		e.emitComment(node.HeadComment, 0)
+
+		err := e.emitSequence(node, 0, false)
+		if err != nil {
+			return err
+		}
+
+		e.emitComment(node.FootComment, 0)
+	default:
+		e.emitBlankLine(node)
+		e.emitComment(node.HeadComment, 0)
+
+		err := e.emitValue(node, 0, 0, "", node.LineComment)
+		if err != nil {
+			return err
+		}
+
+		e.emitComment(node.FootComment, 0)
+	}
+
+	return nil
+}
+
+// emitMapping writes a block mapping whose keys are at indent. When inline is
+// set the first key goes on the line that was already started, such as after
+// the "- " of a sequence item.
+func (e *layoutEmitter) emitMapping(node *yamlv3.Node, indent int, inline bool) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+
+		if i > 0 || !inline {
+			e.emitBlankLine(keyNode)
+			e.emitComment(keyNode.HeadComment, indent)
+			e.writeIndent(indent)
+		}
+
+		err := e.emitPair(keyNode, valueNode, indent)
+		if err != nil {
+			return err
+		}
+
+		e.emitComment(keyNode.FootComment, indent)
+	}
+
+	return nil
+}
+
+func (e *layoutEmitter) emitPair(keyNode, valueNode *yamlv3.Node, indent int) error {
+	key, err := e.inlineText(keyNode, indent, sourceIndent(keyNode), true)
+	if err != nil {
+		return err
+	}
+
+	e.out.WriteString(key + ":")
+
+	lineComment := joinComments(keyNode.LineComment, valueNode.LineComment)
+
+	switch {
+	case isBlockMapping(valueNode):
+		e.writeProperties(valueNode)
+		e.emitLineComment(lineComment)
+		e.out.WriteString("\n")
+
+		childIndent := indent + emitterIndent
+		if keyNode.Line > 0 && valueNode.Content[0].Line > 0 && valueNode.Content[0].Column > keyNode.Column {
+			childIndent = indent + valueNode.Content[0].Column - keyNode.Column
+		}
+
+		e.emitComment(valueNode.HeadComment, childIndent)
+
+		err = e.emitMapping(valueNode, childIndent, false)
+		if err != nil {
+			return err
+		}
+
+		e.emitComment(valueNode.FootComment, childIndent)
+	case isBlockSequence(valueNode):
+		e.writeProperties(valueNode)
+		e.emitLineComment(lineComment)
+		e.out.WriteString("\n")
+
+		sequenceIndent := indent
+		if keyNode.Line > 0 && valueNode.Line > 0 && valueNode.Column > keyNode.Column {
+			sequenceIndent = indent + valueNode.Column - keyNode.Column
+		}
+
+		e.emitComment(valueNode.HeadComment, sequenceIndent)
+
+		err = e.emitSequence(valueNode, sequenceIndent, false)
+		if err != nil {
+			return err
+		}
+
+		e.emitComment(valueNode.FootComment, sequenceIndent)
+	default:
+		err = e.emitValue(valueNode, indent, sourceIndent(keyNode), " ", lineComment)
+		if err != nil {
+			return err
+		}
+
+		e.emitComment(valueNode.FootComment, indent)
+	}
+
+	return nil
+}
+
+// emitSequence writes a block sequence whose dashes are at indent. Nested
+// sequences and mappings start on the line of their dash, as in "- - z1".
+func (e *layoutEmitter) emitSequence(node *yamlv3.Node, indent int, inline bool) error {
+	for i, item := range node.Content {
+		if i > 0 || !inline {
+			e.emitBlankLine(item)
+			e.emitComment(item.HeadComment, indent)
+
+			if isBlockMapping(item) && !hasProperties(item) {
+				e.emitComment(item.Content[0].HeadComment, indent)
+			}
+
+			e.writeIndent(indent)
+		}
+
+		itemIndent := indent + emitterIndent
+		if node.Line > 0 && item.Line > 0 && item.Column > node.Column+1 {
+			itemIndent = indent + item.Column - node.Column
+		}
+
+		e.out.WriteString("-")
+
+		var err error
+		switch {
+		case (isBlockMapping(item) || isBlockSequence(item)) && hasProperties(item):
+			// An item with a tag or an anchor starts on the line after them.
+			e.writeProperties(item)
+			e.out.WriteString("\n")
+
+			if isBlockMapping(item) {
+				err = e.emitMapping(item, itemIndent, false)
+			} else {
+				err = e.emitSequence(item, itemIndent, false)
+			}
+		case isBlockMapping(item):
+			e.writeIndent(itemIndent - indent - 1)
+			err = e.emitMapping(item, itemIndent, true)
+		case isBlockSequence(item):
+			e.writeIndent(itemIndent - indent - 1)
+			err = e.emitSequence(item, itemIndent, true)
+		default:
+			err = e.emitValue(item, indent, sourceIndent(node), " ", item.LineComment)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		e.emitComment(item.FootComment, indent)
+	}
+
+	return nil
+}
+
+// emitValue writes a value that starts on the current line, after separator,
+// and ends the line. A line comment goes on the first line of a block scalar,
+// its header.
+func (e *layoutEmitter) emitValue(node *yamlv3.Node, indent, fromIndent int, separator, lineComment string) error {
+	text, err := e.inlineText(node, indent, fromIndent, false)
+	if err != nil {
+		return err
+	}
+
+	if text != "" {
+		e.out.WriteString(separator)
+	}
+
+	block := isBlockScalarText(text)
+	if block && lineComment != "" {
+		header := strings.SplitN(text, "\n", 2)
+		text = header[0] + " " + lineComment + "\n" + header[1]
+		lineComment = ""
+	}
+
+	e.out.WriteString(text)
+	e.emitLineComment(lineComment)
+	e.out.WriteString("\n")
+	e.endsInBlockScalar = block
+
+	return nil
+}
+
+// inlineText renders a scalar, alias or flow collection, or an empty
+// collection, that starts on a line indented by indent. The source text is
+// used when it still reads as the node; it is moved from fromIndent, the
+// indentation of the line it started on in the source, to indent.
+func (e *layoutEmitter) inlineText(node *yamlv3.Node, indent, fromIndent int, key bool) (string, error) {
+	if node.Kind == yamlv3.AliasNode {
+		return "*" + node.Value, nil
+	}
+
+	if node.Kind == yamlv3.ScalarNode && node.Tag == "!!null" && node.Value == "" && node.Style == 0 {
+		return "", nil
+	}
+
+	if text, ok := e.sourceText(node, fromIndent, key); ok {
+		if text, ok = reindent(text, indent-fromIndent); ok && sourceReadsAs(node, text, indent, key) {
+			return text, nil
+		}
+	}
+
+	text, err := encodeInline(node)
+	if err != nil {
+		return "", err
+	}
+
+	text, _ = reindent(text, indent)
+	return text, nil
+}
+
+// sourceText finds the text of node in the source. Only the first line of a
+// key is looked at, a key that continues on the next line is re-encoded.
+func (e *layoutEmitter) sourceText(node *yamlv3.Node, fromIndent int, key bool) (string, bool) {
+	if node.Line < 1 || node.Line > len(e.lines) || fromIndent < 0 {
+		return "", false
+	}
+
+	line := node.Line - 1
+	start := node.Column - 1
+	if start < 0 || start >= len(e.lines[line]) {
+		return "", false
+	}
+
+	column := skipProperties(e.lines[line], start)
+	if column >= len(e.lines[line]) {
+		return "", false
+	}
+
+	var endLine, endColumn int
+	var ok bool
+
+	switch first := e.lines[line][column]; {
+	case node.Kind == yamlv3.ScalarNode && (first == '"' || first == '\''):
+		endLine, endColumn, ok = e.scanQuoted(line, column)
+	case node.Kind == yamlv3.ScalarNode && (first == '|' || first == '>') && !key:
+		endLine, endColumn, ok = e.scanBlockScalar(line, column, fromIndent)
+	case node.Kind == yamlv3.ScalarNode:
+		endLine, endColumn, ok = e.scanPlain(line, column, fromIndent, key)
+	case first == '[' || first == '{':
+		endLine, endColumn, ok = e.scanFlow(line, column)
+	}
+
+	if !ok || (key && endLine != line) {
+		return "", false
+	}
+
+	return e.text(line, start, endLine, endColumn), true
+}
+
+func (e *layoutEmitter) scanQuoted(line, column int) (int, int, bool) {
+	quote := e.lines[line][column]
+
+	for l, c := line, column+1; l < len(e.lines); l, c = l+1, 0 {
+		for runes := e.lines[l]; c < len(runes); c++ {
+			switch {
+			case quote == '"' && runes[c] == '\\':
+				c++
+			case runes[c] == quote && quote == '\'' && c+1 < len(runes) && runes[c+1] == '\'':
+				c++
+			case runes[c] == quote:
+				return l, c + 1, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// scanBlockScalar includes the content lines up to the first line that is not
+// indented past fromIndent. Trailing empty lines belong to the scalar only
+// when its header keeps them, as in "|+".
+func (e *layoutEmitter) scanBlockScalar(line, column, fromIndent int) (int, int, bool) {
+	header := strings.TrimRight(string(e.lines[line][column:commentStart(e.lines[line], column)]), " \t")
+	keep := strings.Contains(header, "+")
+
+	endLine, endColumn := line, column+len([]rune(header))
+	for l := line + 1; l < len(e.lines); l++ {
+		if isBlankLine(e.lines[l]) {
+			if keep {
+				endLine, endColumn = l, len(e.lines[l])
+			}
+
+			continue
+		}
+
+		if indentation(e.lines[l]) <= fromIndent {
+			break
+		}
+
+		endLine, endColumn = l, len(e.lines[l])
+	}
+
+	return endLine, endColumn, true
+}
+
+// scanPlain includes continuation lines that are indented past fromIndent. A
+// plain key ends at its ':'.
+func (e *layoutEmitter) scanPlain(line, column, fromIndent int, key bool) (int, int, bool) {
+	runes := e.lines[line]
+
+	if key {
+		for c := column; c < len(runes); c++ {
+			if runes[c] == ':' && (c+1 == len(runes) || runes[c+1] == ' ' || runes[c+1] == '\t') {
+				return line, len([]rune(strings.TrimRight(string(runes[:c]), " \t"))), true
+			}
+		}
+
+		return 0, 0, false
+	}
+
+	end := commentStart(runes, column)
+	endLine, endColumn := line, len([]rune(strings.TrimRight(string(runes[:end]), " \t")))
+
+	for l := line + 1; end == len(runes) && l < len(e.lines); l++ {
+		runes = e.lines[l]
+		if isBlankLine(runes) || indentation(runes) <= fromIndent || runes[indentation(runes)] == '#' {
+			break
+		}
+
+		end = commentStart(runes, 0)
+		endLine, endColumn = l, len([]rune(strings.TrimRight(string(runes[:end]), " \t")))
+	}
+
+	return endLine, endColumn, true
+}
+
+func (e *layoutEmitter) scanFlow(line, column int) (int, int, bool) {
+	depth := 0
+	var quote rune
+
+	for l, c := line, column; l < len(e.lines); l, c = l+1, 0 {
+		for runes := e.lines[l]; c < len(runes); c++ {
+			r := runes[c]
+
+			switch {
+			case quote == '\'' && r == '\'':
+				if c+1 < len(runes) && runes[c+1] == '\'' {
+					c++
+					continue
+				}
+				quote = 0
+			case quote == '"' && r == '\\':
+				c++
+			case quote == '"' && r == '"':
+				quote = 0
+			case quote != 0:
+			case r == '\'' || r == '"':
+				quote = r
+			case r == '{' || r == '[':
+				depth++
+			case r == '}' || r == ']':
+				depth--
+				if depth == 0 {
+					return l, c + 1, true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+func (e *layoutEmitter) text(line, column, endLine, endColumn int) string {
+	if line == endLine {
+		return string(e.lines[line][column:endColumn])
+	}
+
+	lines := []string{string(e.lines[line][column:])}
+	for l := line + 1; l < endLine; l++ {
+		lines = append(lines, string(e.lines[l]))
+	}
+	lines = append(lines, string(e.lines[endLine][:endColumn]))
+
+	return strings.Join(lines, "\n")
+}
+
+// sourceReadsAs parses text the way it is about to be written, moved back to
+// the start of the line, and compares the result with node. Comments of the
+// node itself are written separately and are not compared.
+func sourceReadsAs(node *yamlv3.Node, text string, indent int, key bool) bool {
+	text, ok := reindent(text, -indent)
+	if !ok {
+		return false
+	}
+
+	contents := "x: " + text + "\n"
+	if key {
+		contents = text + ": x\n"
+	}
+
+	var document yamlv3.Node
+	err := yamlv3.Unmarshal([]byte(contents), &document)
+	if err != nil || len(document.Content) == 0 || len(document.Content[0].Content) != 2 {
+		return false
+	}
+
+	parsed := document.Content[0].Content[1]
+	if key {
+		parsed = document.Content[0].Content[0]
+	}
+
+	return sameNode(parsed, node, true)
+}
+
+func sameNode(a, b *yamlv3.Node, top bool) bool {
+	if a.Kind != b.Kind || a.Value != b.Value || a.Style != b.Style || a.Tag != b.Tag || a.Anchor != b.Anchor || len(a.Content) != len(b.Content) {
+		return false
+	}
+
+	if !top && (a.HeadComment != b.HeadComment || a.LineComment != b.LineComment || a.FootComment != b.FootComment) {
+		return false
+	}
+
+	for i := range a.Content {
+		if !sameNode(a.Content[i], b.Content[i], false) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// encodeInline writes a node with the yaml.v3 encoder as the value of a key on
+// a line of its own, so that block scalars are indented one level past the
+// line they start on.
+func encodeInline(node *yamlv3.Node) (string, error) {
+	var buffer bytes.Buffer
+	encoder := yamlv3.NewEncoder(&buffer)
+	encoder.SetIndent(emitterIndent)
+
+	err := encoder.Encode(&yamlv3.Node{
+		Kind:    yamlv3.MappingNode,
+		Content: []*yamlv3.Node{{Kind: yamlv3.ScalarNode, Value: "x"}, withoutComments(node)},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	err = encoder.Close()
+	if err != nil {
+		// not tested
+		return "", err
+	}
+
+	text := strings.TrimSuffix(buffer.String(), "\n")
+	return strings.TrimPrefix(strings.TrimPrefix(text, "x:"), " "), nil
+}
+
+// withoutComments copies a node without its comments, which the emitter
+// writes itself. Strings that start with a line break are double quoted, the
+// yaml.v3 encoder drops the leading empty lines of such strings when it
+// writes them as block scalars.
+func withoutComments(node *yamlv3.Node) *yamlv3.Node {
+	copied := *node
+	copied.HeadComment, copied.LineComment, copied.FootComment = "", "", ""
+	copied.Content = nil
+
+	if copied.Kind == yamlv3.ScalarNode && strings.HasPrefix(copied.Value, "\n") && copied.Style&(yamlv3.DoubleQuotedStyle|yamlv3.SingleQuotedStyle) == 0 {
+		copied.Style = yamlv3.DoubleQuotedStyle
+	}
+
+	for _, child := range node.Content {
+		copied.Content = append(copied.Content, withoutComments(child))
+	}
+
+	return &copied
+}
+
+// reindent moves every line after the first by shift columns. It fails when a
+// line would have to lose text to move left.
+func reindent(text string, shift int) (string, bool) {
+	lines := strings.Split(text, "\n")
+
+	for i := 1; i < len(lines); i++ {
+		switch {
+		case lines[i] == "":
+		case shift >= 0:
+			lines[i] = strings.Repeat(" ", shift) + lines[i]
+		case indentation([]rune(lines[i])) >= -shift:
+			lines[i] = lines[i][-shift:]
+		case isBlankLine([]rune(lines[i])):
+			lines[i] = ""
+		default:
+			return "", false
+		}
+	}
+
+	return strings.Join(lines, "\n"), true
+}
+
+// writeProperties writes the tag and the anchor of a block collection.
+func (e *layoutEmitter) writeProperties(node *yamlv3.Node) {
+	if node.Style&yamlv3.TaggedStyle != 0 {
+		e.out.WriteString(" " + node.Tag)
+	}
+
+	if node.Anchor != "" {
+		e.out.WriteString(" &" + node.Anchor)
+	}
+}
+
+func (e *layoutEmitter) writeIndent(indent int) {
+	e.out.WriteString(strings.Repeat(" ", indent))
+	e.endsInBlockScalar = false
+}
+
+func (e *layoutEmitter) emitComment(comment string, indent int) {
+	if comment == "" {
+		return
+	}
+
+	for _, line := range strings.Split(comment, "\n") {
+		if line != "" {
+			e.out.WriteString(strings.Repeat(" ", indent))
+			e.out.WriteString(line)
+		}
+
+		e.out.WriteString("\n")
+	}
+
+	e.endsInBlockScalar = false
+}
+
+func (e *layoutEmitter) emitLineComment(comment string) {
+	if comment != "" {
+		e.out.WriteString(" " + comment)
+	}
+}
+
+// emitBlankLine keeps a blank line in front of entries that had one in the
+// source. A block scalar that keeps its trailing empty lines may already have
+// written it.
+func (e *layoutEmitter) emitBlankLine(node *yamlv3.Node) {
+	if node.Line == 0 || e.out.Len() == 0 || strings.HasSuffix(e.out.String(), "\n\n") {
+		return
+	}
+
+	start := node.Line
+	for _, comment := range []string{node.HeadComment, firstKeyHeadComment(node)} {
+		if comment != "" {
+			start -= strings.Count(comment, "\n") + 1
+		}
+	}
+
+	if start >= 2 && start-2 < len(e.lines) && isBlankLine(e.lines[start-2]) {
+		e.out.WriteString("\n")
+	}
+}
+
+func firstKeyHeadComment(node *yamlv3.Node) string {
+	if isBlockMapping(node) {
+		return node.Content[0].HeadComment
+	}
+
+	return ""
+}
+
+func isBlockMapping(node *yamlv3.Node) bool {
+	return node.Kind == yamlv3.MappingNode && node.Style&yamlv3.FlowStyle == 0 && len(node.Content) > 0
+}
+
+func isBlockSequence(node *yamlv3.Node) bool {
+	return node.Kind == yamlv3.SequenceNode && node.Style&yamlv3.FlowStyle == 0 && len(node.Content) > 0
+}
+
+func isBlockScalarText(text string) bool {
+	runes := []rune(text)
+	column := skipProperties(runes, 0)
+
+	return column < len(runes) && (runes[column] == '|' || runes[column] == '>') && strings.Contains(text, "\n")
+}
+
+func hasProperties(node *yamlv3.Node) bool {
+	return node.Anchor != "" || node.Style&yamlv3.TaggedStyle != 0
+}
+
+// skipProperties skips the tag and anchor in front of a value.
+func skipProperties(runes []rune, column int) int {
+	for column < len(runes) && (runes[column] == '!' || runes[column] == '&') {
+		for column < len(runes) && runes[column] != ' ' {
+			column++
+		}
+
+		for column < len(runes) && runes[column] == ' ' {
+			column++
+		}
+	}
+
+	return column
+}
+
+func sourceIndent(node *yamlv3.Node) int {
+	if node.Line == 0 {
+		return -1
+	}
+
+	return node.Column - 1
+}
+
+func commentStart(runes []rune, column int) int {
+	for c := column; c < len(runes); c++ {
+		if runes[c] == '#' && c > 0 && (runes[c-1] == ' ' || runes[c-1] == '\t') {
+			return c
+		}
+	}
+
+	return len(runes)
+}
+
+func indentation(runes []rune) int {
+	indent := 0
+	for indent < len(runes) && runes[indent] == ' ' {
+		indent++
+	}
+
+	return indent
+}
+
+func isBlankLine(runes []rune) bool {
+	return strings.TrimSpace(string(runes)) == ""
+}
+
+func joinComments(comments ...string) string {
+	joined := []string{}
+	for _, comment := range comments {
+		if comment != "" {
+			joined = append(joined, comment)
+		}
+	}
+
+	return strings.Join(joined, " ")
+}
@@ -0,0 +1,342 @@
+package ops
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cppforlife/go-patch/patch"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var (
+	ErrNameNotFound          = errors.New("could not find name in manifest")
+	ErrInstanceGroupNotFound = errors.New("could not find instance group in manifest")
+	ErrDocumentNotFound      = errors.New("could not find document in stream")
+)
+
+type OpErrorCause int
+
+const (
+	UnknownCause OpErrorCause = iota
+	BadPointer
+	MissingKey
+	MissingNameMatch
+	AmbiguousNameMatch
+	IndexOutOfRange
+)
+
+func (c OpErrorCause) String() string {
+	switch c {
+	case BadPointer:
+		return "bad pointer"
+	case MissingKey:
+		return "missing key"
+	case MissingNameMatch:
+		return "missing name match"
+	case AmbiguousNameMatch:
+		return "ambiguous name match"
+	case IndexOutOfRange:
+		return "index out of range"
+	default:
+		return "unknown"
+	}
+}
+
+// OpError is returned by ApplyOps when one of the ops cannot be built or
+// applied. Index is the position of the failing op in the list that was given.
+// Line and Column locate the parent of the missing node in the source
+// manifest, and are zero when the parent is not part of the source, such as
+// when an earlier op added it. Names lists the names that a name= selector
+// could have matched, and Suggestion is the closest of those names or map keys
+// to the one that was asked for.
+type OpError struct {
+	Index      int
+	Type       string
+	Path       string
+	Cause      OpErrorCause
+	Err        error
+	Line       int
+	Column     int
+	Names      []string
+	Suggestion string
+}
+
+func (e OpError) Error() string {
+	message := fmt.Sprintf("op %d (%s '%s') failed: %s", e.Index, e.Type, e.Path, e.Err)
+
+	if e.Line > 0 {
+		message += fmt.Sprintf(" (parent at line %d, column %d)", e.Line, e.Column)
+	}
+
+	if e.Cause == MissingNameMatch && len(e.Names) > 0 {
+		message += fmt.Sprintf(": found names '%s'", strings.Join(e.Names, "', '"))
+	}
+
+	if e.Suggestion != "" {
+		message += fmt.Sprintf(", did you mean '%s'?", e.Suggestion)
+	}
+
+	return message
+}
+
+func (e OpError) Unwrap() error {
+	return e.Err
+}
+
+func newOpError(index int, op Op, cause OpErrorCause, err error) OpError {
+	return OpError{
+		Index: index,
+		Type:  op.Type,
+		Path:  op.Path,
+		Cause: cause,
+		Err:   err,
+	}
+}
+
+func opErrorCause(err error) OpErrorCause {
+	var missingMapKeyErr patch.OpMissingMapKeyErr
+	if errors.As(err, &missingMapKeyErr) {
+		return MissingKey
+	}
+
+	var missingIndexErr patch.OpMissingIndexErr
+	if errors.As(err, &missingIndexErr) {
+		return IndexOutOfRange
+	}
+
+	var multipleMatchingIndexErr patch.OpMultipleMatchingIndexErr
+	if errors.As(err, &multipleMatchingIndexErr) {
+		if len(multipleMatchingIndexErr.Idxs) == 0 {
+			return MissingNameMatch
+		}
+
+		return AmbiguousNameMatch
+	}
+
+	return UnknownCause
+}
+
+// describe fills in the names, suggestion and source position of the parent
+// node that the failing op could not get past. doc is the document the op was
+// applied to and original the one that was parsed from source.
+func (e *OpError) describe(source string, original, doc interface{}) {
+	var path patch.Pointer
+	var missing string
+	var candidates []string
+
+	var missingMapKeyErr patch.OpMissingMapKeyErr
+	var missingIndexErr patch.OpMissingIndexErr
+	var multipleMatchingIndexErr patch.OpMultipleMatchingIndexErr
+
+	switch {
+	case errors.As(e.Err, &missingMapKeyErr):
+		path = missingMapKeyErr.Path
+		missing = missingMapKeyErr.Key
+		for key := range missingMapKeyErr.Obj {
+			candidates = append(candidates, fmt.Sprint(key))
+		}
+		sort.Strings(candidates)
+	case errors.As(e.Err, &missingIndexErr):
+		path = missingIndexErr.Path
+	case errors.As(e.Err, &multipleMatchingIndexErr):
+		path = multipleMatchingIndexErr.Path
+	default:
+		return
+	}
+
+	tokens := path.Tokens()
+	if len(tokens) < 2 {
+		return
+	}
+	parent := patch.NewPointer(tokens[:len(tokens)-1])
+
+	if token, ok := tokens[len(tokens)-1].(patch.MatchingIndexToken); ok && e.Cause == MissingNameMatch {
+		missing = token.Value
+		candidates = matchingNames(doc, parent, token.Key)
+		e.Names = candidates
+	}
+
+	e.Suggestion = closestName(missing, candidates)
+	e.Line, e.Column = sourcePosition(source, original, doc, parent)
+}
+
+func matchingNames(doc interface{}, parent patch.Pointer, key string) []string {
+	names := []string{}
+
+	items, err := patch.FindOp{Path: parent}.Apply(doc)
+	if err != nil {
+		return names
+	}
+
+	typedItems, ok := items.([]interface{})
+	if !ok {
+		return names
+	}
+
+	for _, item := range typedItems {
+		if typedItem, ok := item.(map[interface{}]interface{}); ok {
+			if name, ok := typedItem[key]; ok {
+				names = append(names, fmt.Sprint(name))
+			}
+		}
+	}
+
+	return names
+}
+
+// closestName returns the candidate with the smallest edit distance to name,
+// as long as the two are close enough to pass for a typo.
+func closestName(name string, candidates []string) string {
+	closest := ""
+	closestDistance := len(name)/2 + 1
+
+	for _, candidate := range candidates {
+		distance := editDistance(name, candidate)
+		if distance < closestDistance {
+			closest = candidate
+			closestDistance = distance
+		}
+	}
+
+	return closest
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			substitution := previous[j-1]
+			if a[i-1] != b[j-1] {
+				substitution++
+			}
+
+			current[j] = substitution
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+
+		previous = current
+	}
+
+	return previous[len(b)]
+}
+
+// sourcePosition finds the node at path in doc and returns the line and
+// column it has in the source manifest. doc is carried over to the node tree
+// of the source first, so a path whose items were moved by earlier ops still
+// leads to the right node. Zeros are returned when the path does not lead to
+// a node that came from the source.
+func sourcePosition(source string, original, doc interface{}, path patch.Pointer) (int, int) {
+	var document yamlv3.Node
+	err := yamlv3.Unmarshal([]byte(source), &document)
+	if err != nil || len(document.Content) == 0 {
+		return 0, 0
+	}
+
+	root, err := layoutPatcher{}.reconcile(document.Content[0], original, doc)
+	if err != nil {
+		// not tested
+		return 0, 0
+	}
+
+	node := nodeAt(root, path)
+	if node == nil {
+		return 0, 0
+	}
+
+	return node.Line, node.Column
+}
+
+// nodeAt follows path through a node tree the way go-patch follows it through
+// the parsed value. It returns nil where the path leads nowhere.
+func nodeAt(node *yamlv3.Node, path patch.Pointer) *yamlv3.Node {
+	for _, token := range path.Tokens() {
+		switch typedToken := token.(type) {
+		case patch.RootToken:
+		case patch.KeyToken:
+			node = mappingValue(node, typedToken.Key)
+		case patch.IndexToken:
+			if node.Kind != yamlv3.SequenceNode || typedToken.Index < 0 || typedToken.Index >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[typedToken.Index]
+		case patch.MatchingIndexToken:
+			node = matchingItem(node, typedToken.Key, typedToken.Value)
+		default:
+			return nil
+		}
+
+		if node == nil {
+			return nil
+		}
+	}
+
+	return node
+}
+
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func matchingItem(node *yamlv3.Node, key, value string) *yamlv3.Node {
+	if node.Kind != yamlv3.SequenceNode {
+		return nil
+	}
+
+	for _, item := range node.Content {
+		if itemValue := mappingValue(item, key); itemValue != nil && itemValue.Value == value {
+			return item
+		}
+	}
+
+	return nil
+}
+
+type instanceGroupNotFoundErr struct {
+	name string
+}
+
+func (e instanceGroupNotFoundErr) Error() string {
+	return fmt.Sprintf("could not find instance group %s in manifest", e.name)
+}
+
+func (e instanceGroupNotFoundErr) Is(target error) bool {
+	return target == ErrInstanceGroupNotFound
+}
+
+type documentNotFoundErr struct {
+	selector DocumentSelector
+	count    int
+}
+
+func (e documentNotFoundErr) Error() string {
+	return fmt.Sprintf("could not find document %s in stream of %d documents", e.selector, e.count)
+}
+
+func (e documentNotFoundErr) Is(target error) bool {
+	return target == ErrDocumentNotFound
+}
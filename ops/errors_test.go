@@ -0,0 +1,192 @@
+package ops_test
+
+import (
+	"errors"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Errors", func() {
+	Describe("OpError", func() {
+		var manifest string
+
+		BeforeEach(func() {
+			manifest = `name: some-name
+instance_groups:
+- name: consul
+  azs: [z1]`
+		})
+
+		expectOpError := func(op ops.Op, cause ops.OpErrorCause, message string) {
+			_, err := ops.ApplyOps(manifest, []ops.Op{
+				{Type: "replace", Path: "/name", Value: "some-changed-name"},
+				op,
+			})
+
+			var opErr ops.OpError
+			Expect(errors.As(err, &opErr)).To(BeTrue())
+			Expect(opErr.Index).To(Equal(1))
+			Expect(opErr.Type).To(Equal(op.Type))
+			Expect(opErr.Path).To(Equal(op.Path))
+			Expect(opErr.Cause).To(Equal(cause))
+			Expect(err).To(MatchError(message))
+		}
+
+		Context("when the pointer is bad", func() {
+			It("identifies the failing op and the cause", func() {
+				expectOpError(ops.Op{Type: "replace", Path: "name", Value: "some-name"}, ops.BadPointer,
+					"op 1 (replace 'name') failed: Expected to start with '/'")
+			})
+		})
+
+		Context("when a map key is missing", func() {
+			It("identifies the failing op and the cause", func() {
+				expectOpError(ops.Op{Type: "remove", Path: "/director_uuid"}, ops.MissingKey,
+					"op 1 (remove '/director_uuid') failed: Expected to find a map key 'director_uuid' for path '/director_uuid' (found map keys: 'instance_groups', 'name') (parent at line 1, column 1)")
+			})
+		})
+
+		Context("when no item matches the name", func() {
+			It("identifies the failing op and the cause", func() {
+				expectOpError(ops.Op{Type: "replace", Path: "/instance_groups/name=etcd/azs", Value: []string{"z2"}}, ops.MissingNameMatch,
+					"op 1 (replace '/instance_groups/name=etcd/azs') failed: Expected to find exactly one matching array item for path '/instance_groups/name=etcd' but found 0 (parent at line 3, column 1): found names 'consul'")
+			})
+		})
+
+		Context("when the array has no names to list", func() {
+			It("leaves the names out of the message", func() {
+				manifest = "name: some-name\ninstance_groups: []"
+
+				expectOpError(ops.Op{Type: "remove", Path: "/instance_groups/name=consul"}, ops.MissingNameMatch,
+					"op 1 (remove '/instance_groups/name=consul') failed: Expected to find exactly one matching array item for path '/instance_groups/name=consul' but found 0 (parent at line 2, column 18)")
+			})
+		})
+
+		Context("when the index is out of range", func() {
+			It("identifies the failing op and the cause", func() {
+				expectOpError(ops.Op{Type: "remove", Path: "/instance_groups/0/azs/3"}, ops.IndexOutOfRange,
+					"op 1 (remove '/instance_groups/0/azs/3') failed: Expected to find array index '3' but found array of length '1' for path '/instance_groups/0/azs/3' (parent at line 4, column 8)")
+			})
+		})
+
+		Context("when a test op cannot find its path", func() {
+			It("identifies the failing op and the cause", func() {
+				expectOpError(ops.Op{Type: "test", Path: "/instance_groups/name=etcd", Value: "some-value"}, ops.MissingNameMatch,
+					"op 1 (test '/instance_groups/name=etcd') failed: test op failed: expected '/instance_groups/name=etcd' to be 'some-value': Expected to find exactly one matching array item for path '/instance_groups/name=etcd' but found 0 (parent at line 3, column 1): found names 'consul'")
+			})
+		})
+
+		Context("when the op type is not supported", func() {
+			It("identifies the failing op and the cause", func() {
+				expectOpError(ops.Op{Type: "other", Path: "/name"}, ops.UnknownCause,
+					"op 1 (other '/name') failed: op type other not supported by destiny")
+			})
+		})
+
+		Context("when a name= selector has a typo", func() {
+			It("lists the names that exist and suggests the closest one", func() {
+				manifest = `name: some-name
+
+instance_groups:
+- name: consul
+  azs: [z1]
+- name: testconsumer
+  azs: [z1]`
+
+				_, err := ops.ApplyOps(manifest, []ops.Op{
+					{Type: "replace", Path: "/instance_groups/name=consull/azs", Value: []string{"z2"}},
+				})
+
+				var opErr ops.OpError
+				Expect(errors.As(err, &opErr)).To(BeTrue())
+				Expect(opErr.Names).To(Equal([]string{"consul", "testconsumer"}))
+				Expect(opErr.Suggestion).To(Equal("consul"))
+				Expect(opErr.Line).To(Equal(4))
+				Expect(opErr.Column).To(Equal(1))
+				Expect(err).To(MatchError("op 0 (replace '/instance_groups/name=consull/azs') failed: Expected to find exactly one matching array item for path '/instance_groups/name=consull' but found 0 (parent at line 4, column 1): found names 'consul', 'testconsumer', did you mean 'consul'?"))
+			})
+		})
+
+		Context("when a map key has a typo", func() {
+			It("suggests the closest key", func() {
+				_, err := ops.ApplyOps(manifest, []ops.Op{
+					{Type: "replace", Path: "/instance_groups/name=consul/azz/-", Value: "z2"},
+				})
+
+				var opErr ops.OpError
+				Expect(errors.As(err, &opErr)).To(BeTrue())
+				Expect(opErr.Suggestion).To(Equal("azs"))
+				Expect(opErr.Line).To(Equal(3))
+				Expect(opErr.Column).To(Equal(3))
+			})
+		})
+
+		Context("when nothing is close to the missing name", func() {
+			It("does not make a suggestion", func() {
+				_, err := ops.ApplyOps(manifest, []ops.Op{
+					{Type: "remove", Path: "/instance_groups/name=some-unrelated-name"},
+				})
+
+				var opErr ops.OpError
+				Expect(errors.As(err, &opErr)).To(BeTrue())
+				Expect(opErr.Names).To(Equal([]string{"consul"}))
+				Expect(opErr.Suggestion).To(BeEmpty())
+			})
+		})
+
+		Context("when the parent was added by an earlier op", func() {
+			It("does not report a source position", func() {
+				_, err := ops.ApplyOps(manifest, []ops.Op{
+					{Type: "replace", Path: "/jobs?", Value: []interface{}{map[interface{}]interface{}{"name": "consul_agent"}}},
+					{Type: "remove", Path: "/jobs/name=consul-agent"},
+				})
+
+				var opErr ops.OpError
+				Expect(errors.As(err, &opErr)).To(BeTrue())
+				Expect(opErr.Suggestion).To(Equal("consul_agent"))
+				Expect(opErr.Line).To(Equal(0))
+				Expect(opErr.Column).To(Equal(0))
+			})
+		})
+
+		Context("when an earlier op moved the parent", func() {
+			It("reports the source position of the item the path now leads to", func() {
+				manifest = `name: some-name
+instance_groups:
+- name: consul
+  azs: [z1]
+- name: etcd
+  azs: [z1]`
+
+				_, err := ops.ApplyOps(manifest, []ops.Op{
+					{Type: "remove", Path: "/instance_groups/name=consul"},
+					{Type: "remove", Path: "/instance_groups/0/azs/3"},
+				})
+
+				var opErr ops.OpError
+				Expect(errors.As(err, &opErr)).To(BeTrue())
+				Expect(opErr.Line).To(Equal(6))
+				Expect(opErr.Column).To(Equal(8))
+			})
+		})
+
+		It("describes the cause", func() {
+			Expect(ops.MissingNameMatch.String()).To(Equal("missing name match"))
+			Expect(ops.UnknownCause.String()).To(Equal("unknown"))
+		})
+	})
+
+	Describe("retriever errors", func() {
+		It("returns sentinel errors that can be checked with errors.Is", func() {
+			_, err := ops.ManifestName("hello: world")
+			Expect(errors.Is(err, ops.ErrNameNotFound)).To(BeTrue())
+
+			_, err = ops.AZs("instance_groups: []", "consul")
+			Expect(errors.Is(err, ops.ErrInstanceGroupNotFound)).To(BeTrue())
+			Expect(err).To(MatchError("could not find instance group consul in manifest"))
+		})
+	})
+})
@@ -0,0 +1,305 @@
+package ops
+
+import (
+	"fmt"
+
+	"github.com/cppforlife/go-patch/patch"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// NotFoundError is returned by the typed lookups when nothing exists at a
+// path that is not optional.
+type NotFoundError struct {
+	Path string
+	Err  error
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("could not find '%s' in manifest: %s", e.Path, e.Err)
+}
+
+func (e NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// WrongTypeError is returned by the typed lookups when the value at a path
+// exists but cannot be converted to the type that was asked for.
+type WrongTypeError struct {
+	Path     string
+	Expected string
+	Value    interface{}
+}
+
+func (e WrongTypeError) Error() string {
+	return fmt.Sprintf("expected '%s' to be %s but found '%v' (%T)", e.Path, e.Expected, e.Value, e.Value)
+}
+
+func FindString(manifest, path string) (string, bool, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return "", false, err
+	}
+
+	return document.FindString(path)
+}
+
+func FindInt(manifest, path string) (int, bool, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return document.FindInt(path)
+}
+
+func FindBool(manifest, path string) (bool, bool, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return false, false, err
+	}
+
+	return document.FindBool(path)
+}
+
+func FindStringSlice(manifest, path string) ([]string, bool, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return document.FindStringSlice(path)
+}
+
+func FindMap(manifest, path string) (map[string]interface{}, bool, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return document.FindMap(path)
+}
+
+func FindInto(manifest, path string, target interface{}) (bool, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return false, err
+	}
+
+	return document.FindInto(path, target)
+}
+
+// FindString returns the string at path. Any other scalar but null, such as a
+// version pin of 1.10 or 3, is returned the way the manifest spelled it.
+func (d Document) FindString(path string) (string, bool, error) {
+	value, found, err := d.lookup(path)
+	if err != nil || !found {
+		return "", found, err
+	}
+
+	if typedValue, ok := value.(string); ok {
+		return typedValue, true, nil
+	}
+
+	if !isScalar(value) {
+		return "", true, WrongTypeError{Path: path, Expected: "a string", Value: value}
+	}
+
+	var spelledValue string
+	err = d.decodeRestored(path, value, &spelledValue)
+	return spelledValue, true, err
+}
+
+func (d Document) FindInt(path string) (int, bool, error) {
+	value, found, err := d.lookup(path)
+	if err != nil || !found {
+		return 0, found, err
+	}
+
+	switch typedValue := value.(type) {
+	case int:
+		return typedValue, true, nil
+	case int64:
+		if int64(int(typedValue)) == typedValue {
+			return int(typedValue), true, nil
+		}
+	}
+
+	return 0, true, WrongTypeError{Path: path, Expected: "an int", Value: value}
+}
+
+func (d Document) FindBool(path string) (bool, bool, error) {
+	value, found, err := d.lookup(path)
+	if err != nil || !found {
+		return false, found, err
+	}
+
+	typedValue, ok := value.(bool)
+	if !ok {
+		return false, true, WrongTypeError{Path: path, Expected: "a bool", Value: value}
+	}
+
+	return typedValue, true, nil
+}
+
+// FindStringSlice returns the strings at path, spelling the other scalars of
+// the list the way FindString does.
+func (d Document) FindStringSlice(path string) ([]string, bool, error) {
+	value, found, err := d.lookup(path)
+	if err != nil || !found {
+		return nil, found, err
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, true, WrongTypeError{Path: path, Expected: "a list of strings", Value: value}
+	}
+
+	for _, item := range items {
+		if !isScalar(item) {
+			return nil, true, WrongTypeError{Path: path, Expected: "a list of strings", Value: value}
+		}
+	}
+
+	values := []string{}
+	err = d.decodeRestored(path, value, &values)
+	if err != nil {
+		// not tested
+		return nil, true, err
+	}
+
+	return values, true, nil
+}
+
+func (d Document) FindMap(path string) (map[string]interface{}, bool, error) {
+	value, found, err := d.lookup(path)
+	if err != nil || !found {
+		return nil, found, err
+	}
+
+	typedValue, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, true, WrongTypeError{Path: path, Expected: "a map", Value: value}
+	}
+
+	m := map[string]interface{}{}
+	for key, child := range typedValue {
+		typedKey, ok := key.(string)
+		if !ok {
+			return nil, true, WrongTypeError{Path: path, Expected: "a map with string keys", Value: value}
+		}
+
+		m[typedKey] = child
+	}
+
+	return m, true, nil
+}
+
+// FindInto decodes the value at path into target the same way yaml.Unmarshal
+// would, so target can be any type that the value could be unmarshalled into.
+func (d Document) FindInto(path string, target interface{}) (bool, error) {
+	value, found, err := d.lookup(path)
+	if err != nil || !found {
+		return found, err
+	}
+
+	err = d.decodeRestored(path, value, target)
+	if err != nil {
+		return true, WrongTypeError{Path: path, Expected: fmt.Sprintf("decodable into %T", target), Value: value}
+	}
+
+	return true, nil
+}
+
+// decodeRestored decodes value, found at path, into target with every scalar
+// that no op changed spelled the way the manifest spelled it. A version of
+// 1.10 decodes into a string as "1.10" instead of "1.1".
+func (d Document) decodeRestored(path string, value, target interface{}) error {
+	contents, err := yaml.Marshal(value)
+	if err != nil {
+		// not tested
+		return err
+	}
+
+	restoredContents := string(contents)
+
+	pointer, err := patch.NewPointerFromString(path)
+	if err != nil {
+		// not tested
+		return err
+	}
+
+	original, err := patch.FindOp{Path: pointer}.Apply(d.original)
+	if err == nil {
+		restoredContents, err = d.tree.restoreScalarsAt(pointer, restoredContents, original, value)
+		if err != nil {
+			// not tested
+			return err
+		}
+	}
+
+	return yaml.Unmarshal([]byte(restoredContents), target)
+}
+
+// isScalar reports whether value is a string, number or bool, which are the
+// scalars that the string lookups accept.
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, bool, int, int64, uint64, float64:
+		return true
+	}
+
+	return false
+}
+
+// lookup finds the value at path. A path with an optional token that leads
+// nowhere is reported as not found without an error.
+func (d Document) lookup(path string) (interface{}, bool, error) {
+	pointer, err := patch.NewPointerFromString(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	optional := isOptional(pointer)
+
+	value, err := patch.FindOp{Path: pointer}.Apply(d.doc)
+	if err != nil {
+		var notFound bool
+		switch opErrorCause(err) {
+		case MissingKey, MissingNameMatch, IndexOutOfRange:
+			notFound = true
+		}
+
+		switch {
+		case notFound && optional:
+			return nil, false, nil
+		case notFound:
+			return nil, false, NotFoundError{Path: path, Err: err}
+		default:
+			return nil, false, err
+		}
+	}
+
+	if value == nil && optional {
+		return nil, false, nil
+	}
+
+	return copyValue(value), true, nil
+}
+
+func isOptional(pointer patch.Pointer) bool {
+	for _, token := range pointer.Tokens() {
+		switch typedToken := token.(type) {
+		case patch.KeyToken:
+			if typedToken.Optional {
+				return true
+			}
+		case patch.MatchingIndexToken:
+			if typedToken.Optional {
+				return true
+			}
+		}
+	}
+
+	return false
+}
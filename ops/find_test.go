@@ -0,0 +1,221 @@
+package ops_test
+
+import (
+	"errors"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Find", func() {
+	var manifest string
+
+	BeforeEach(func() {
+		manifest = `name: some-name
+instance_groups:
+- name: consul
+  instances: 3
+  azs: [z1, z2]
+  properties:
+    consul:
+      agent:
+        domain: cf.internal
+        servers:
+          lan: [10.0.4.2]
+    etcd:
+      require_ssl: true`
+	})
+
+	Describe("FindString", func() {
+		It("returns the string at the path", func() {
+			domain, found, err := ops.FindString(manifest, "/instance_groups/name=consul/properties/consul/agent/domain")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(domain).To(Equal("cf.internal"))
+		})
+
+		It("returns a zero value when an optional path does not exist", func() {
+			domain, found, err := ops.FindString(manifest, "/instance_groups/name=consul/properties/consul/agent/datacenter?")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(domain).To(BeEmpty())
+
+			domain, found, err = ops.FindString(manifest, "/instance_groups/name=etcd?/properties/etcd/domain")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(domain).To(BeEmpty())
+		})
+
+		It("returns other scalars the way the manifest spelled them", func() {
+			for path, spelling := range map[string]string{
+				"/version":  "3",
+				"/release":  "1.10",
+				"/enabled":  "on",
+				"/checksum": "0x1F",
+			} {
+				value, found, err := ops.FindString("version: 3\nrelease: 1.10\nenabled: on\nchecksum: 0x1F", path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(value).To(Equal(spelling))
+			}
+		})
+
+		Context("failure cases", func() {
+			Context("when the path does not exist", func() {
+				It("returns a not found error", func() {
+					_, found, err := ops.FindString(manifest, "/instance_groups/name=consul/properties/consul/agent/datacenter")
+					Expect(found).To(BeFalse())
+					Expect(err).To(MatchError("could not find '/instance_groups/name=consul/properties/consul/agent/datacenter' in manifest: Expected to find a map key 'datacenter' for path '/instance_groups/name=consul/properties/consul/agent/datacenter' (found map keys: 'domain', 'servers')"))
+
+					var notFoundErr ops.NotFoundError
+					Expect(errors.As(err, &notFoundErr)).To(BeTrue())
+				})
+			})
+
+			Context("when the value has the wrong type", func() {
+				It("returns a wrong type error", func() {
+					_, found, err := ops.FindString(manifest, "/instance_groups/name=consul/azs")
+					Expect(found).To(BeTrue())
+					Expect(err).To(MatchError("expected '/instance_groups/name=consul/azs' to be a string but found '[z1 z2]' ([]interface {})"))
+
+					var wrongTypeErr ops.WrongTypeError
+					Expect(errors.As(err, &wrongTypeErr)).To(BeTrue())
+
+					var notFoundErr ops.NotFoundError
+					Expect(errors.As(err, &notFoundErr)).To(BeFalse())
+				})
+			})
+
+			Context("when the path is bad", func() {
+				It("returns an error", func() {
+					_, _, err := ops.FindString(manifest, "name")
+					Expect(err).To(MatchError("Expected to start with '/'"))
+				})
+			})
+
+			Context("when the manifest yaml is invalid", func() {
+				It("returns an error", func() {
+					_, _, err := ops.FindString("%%%", "/name")
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+				})
+			})
+		})
+	})
+
+	Describe("FindInt", func() {
+		It("returns the int at the path", func() {
+			instances, found, err := ops.FindInt(manifest, "/instance_groups/name=consul/instances")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(instances).To(Equal(3))
+		})
+
+		Context("failure cases", func() {
+			Context("when the value has the wrong type", func() {
+				It("returns a wrong type error", func() {
+					_, _, err := ops.FindInt(manifest, "/name")
+					Expect(err).To(MatchError("expected '/name' to be an int but found 'some-name' (string)"))
+				})
+			})
+		})
+	})
+
+	Describe("FindBool", func() {
+		It("returns the bool at the path", func() {
+			requireSSL, found, err := ops.FindBool(manifest, "/instance_groups/name=consul/properties/etcd/require_ssl")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(requireSSL).To(BeTrue())
+		})
+
+		Context("failure cases", func() {
+			Context("when the value has the wrong type", func() {
+				It("returns a wrong type error", func() {
+					_, _, err := ops.FindBool(manifest, "/name")
+					Expect(err).To(MatchError("expected '/name' to be a bool but found 'some-name' (string)"))
+				})
+			})
+		})
+	})
+
+	Describe("FindStringSlice", func() {
+		It("returns the strings at the path", func() {
+			azs, found, err := ops.FindStringSlice(manifest, "/instance_groups/name=consul/azs")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(azs).To(Equal([]string{"z1", "z2"}))
+		})
+
+		It("returns other scalars the way the manifest spelled them", func() {
+			versions, _, err := ops.FindStringSlice("versions: [3, 1.10, latest]", "/versions")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(Equal([]string{"3", "1.10", "latest"}))
+		})
+
+		Context("failure cases", func() {
+			Context("when an item is not a string", func() {
+				It("returns a wrong type error", func() {
+					_, _, err := ops.FindStringSlice("ports: [1, ~]", "/ports")
+					Expect(err).To(MatchError("expected '/ports' to be a list of strings but found '[1 <nil>]' ([]interface {})"))
+				})
+			})
+		})
+	})
+
+	Describe("FindMap", func() {
+		It("returns the map at the path", func() {
+			agent, found, err := ops.FindMap(manifest, "/instance_groups/name=consul/properties/consul/agent")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(agent).To(HaveKeyWithValue("domain", "cf.internal"))
+		})
+
+		Context("failure cases", func() {
+			Context("when the value has the wrong type", func() {
+				It("returns a wrong type error", func() {
+					_, _, err := ops.FindMap(manifest, "/name")
+					Expect(err).To(MatchError("expected '/name' to be a map but found 'some-name' (string)"))
+				})
+			})
+		})
+	})
+
+	Describe("FindInto", func() {
+		It("decodes the value at the path into the target", func() {
+			var agent struct {
+				Domain  string
+				Servers struct {
+					LAN []string `yaml:"lan"`
+				}
+			}
+
+			found, err := ops.FindInto(manifest, "/instance_groups/name=consul/properties/consul/agent", &agent)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(agent.Domain).To(Equal("cf.internal"))
+			Expect(agent.Servers.LAN).To(Equal([]string{"10.0.4.2"}))
+		})
+
+		It("leaves the target alone when an optional path does not exist", func() {
+			target := []string{"some-value"}
+
+			found, err := ops.FindInto(manifest, "/instance_groups/name=consul/vm_extensions?", &target)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(target).To(Equal([]string{"some-value"}))
+		})
+
+		Context("failure cases", func() {
+			Context("when the value cannot be decoded into the target", func() {
+				It("returns a wrong type error", func() {
+					var azs map[string]string
+
+					_, err := ops.FindInto(manifest, "/instance_groups/name=consul/azs", &azs)
+					Expect(err).To(MatchError("expected '/instance_groups/name=consul/azs' to be decodable into *map[string]string but found '[z1 z2]' ([]interface {})"))
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,129 @@
+package ops
+
+import (
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var (
+	topLevelKeyOrder = []string{
+		"name",
+		"releases",
+		"stemcells",
+		"update",
+		"addons",
+		"features",
+		"variables",
+		"instance_groups",
+	}
+
+	instanceGroupKeyOrder = []string{
+		"name",
+		"azs",
+		"instances",
+		"lifecycle",
+		"jobs",
+		"vm_type",
+		"stemcell",
+		"persistent_disk_type",
+		"networks",
+		"properties",
+	}
+)
+
+// Format re-emits every document of a manifest in the order BOSH manifests
+// are conventionally written in.
+func Format(manifest string) (string, error) {
+	stream, err := ParseStream(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	return stream.join(Document.Format, false)
+}
+
+// Format renders the document with its top level keys and the keys of its
+// instance groups in the conventional BOSH order. Keys without a conventional
+// place follow in alphabetical order. Multiline strings, such as certificates
+// and keys, are written as literal blocks. Scalars are spelled the way the
+// source spelled them.
+func (d Document) Format() (string, error) {
+	output, err := yaml.Marshal(d.doc)
+	if err != nil {
+		// not tested
+		return "", err
+	}
+
+	document, err := d.tree.restoredDocument(string(output), d.original, d.doc)
+	if err != nil {
+		// not tested
+		return "", err
+	}
+
+	if len(document.Content) > 0 {
+		root := document.Content[0]
+		orderKeys(root, topLevelKeyOrder)
+
+		if instanceGroups := mappingValue(root, "instance_groups"); instanceGroups != nil && instanceGroups.Kind == yamlv3.SequenceNode {
+			for _, instanceGroup := range instanceGroups.Content {
+				orderKeys(instanceGroup, instanceGroupKeyOrder)
+			}
+		}
+
+		useLiteralBlocks(root)
+	}
+
+	return encodeDocument(string(output), document)
+}
+
+// orderKeys moves the keys of a mapping that appear in order to its front.
+// The other keys keep their relative order.
+func orderKeys(node *yamlv3.Node, order []string) {
+	if node.Kind != yamlv3.MappingNode {
+		return
+	}
+
+	ordered := []*yamlv3.Node{}
+	for _, key := range order {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Kind == yamlv3.ScalarNode && node.Content[i].Value == key {
+				ordered = append(ordered, node.Content[i], node.Content[i+1])
+				break
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !containsString(order, node.Content[i].Value) || node.Content[i].Kind != yamlv3.ScalarNode {
+			ordered = append(ordered, node.Content[i], node.Content[i+1])
+		}
+	}
+
+	node.Content = ordered
+}
+
+func useLiteralBlocks(node *yamlv3.Node) {
+	if node.Kind == yamlv3.ScalarNode {
+		if strings.Contains(node.Value, "\n") && !strings.Contains(node.Value, "\r") {
+			node.Style = yamlv3.LiteralStyle
+		}
+
+		return
+	}
+
+	for _, child := range node.Content {
+		useLiteralBlocks(child)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,237 @@
+package ops
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var variableRegexp = regexp.MustCompile(`\(\((!?[-/\.\w\pL]+)\)\)`)
+
+type InterpolateOptions struct {
+	Vars      map[string]interface{}
+	VarsFiles []string
+	VarsStore string
+	Strict    bool
+}
+
+type MissingVariablesError struct {
+	Names []string
+}
+
+func (e MissingVariablesError) Error() string {
+	return fmt.Sprintf("Expected to find variables: %s", strings.Join(e.Names, ", "))
+}
+
+func Interpolate(manifest string, options InterpolateOptions) (string, error) {
+	return Renderer{}.Interpolate(manifest, options)
+}
+
+func (r Renderer) Interpolate(manifest string, options InterpolateOptions) (string, error) {
+	vars := map[string]interface{}{}
+	for _, varsFile := range options.VarsFiles {
+		fileVars, err := LoadVarsFile(varsFile)
+		if err != nil {
+			return "", err
+		}
+
+		for name, value := range fileVars {
+			vars[name] = value
+		}
+	}
+
+	for name, value := range options.Vars {
+		vars[name] = value
+	}
+
+	if options.VarsStore != "" {
+		var err error
+		vars, err = generateIntoVarsStore(manifest, VarsStore{Path: options.VarsStore}, vars)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	interpolator := interpolator{
+		vars:    vars,
+		missing: map[string]struct{}{},
+	}
+
+	doc, err := interpolator.interpolate(document.doc)
+	if err != nil {
+		return "", err
+	}
+
+	if options.Strict && len(interpolator.missing) > 0 {
+		names := []string{}
+		for name := range interpolator.missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return "", MissingVariablesError{Names: names}
+	}
+
+	return r.RenderDocument(Document{
+		source:   document.source,
+		tree:     document.tree,
+		original: document.original,
+		doc:      doc,
+	})
+}
+
+func generateIntoVarsStore(manifest string, store VarsStore, provided map[string]interface{}) (map[string]interface{}, error) {
+	storedVars, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]interface{}{}
+	for name, value := range storedVars {
+		vars[name] = value
+	}
+
+	for name, value := range provided {
+		vars[name] = value
+	}
+
+	vars, err = GenerateVariables(manifest, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range vars {
+		if _, ok := provided[name]; !ok {
+			storedVars[name] = value
+		}
+	}
+
+	err = store.Save(storedVars)
+	if err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
+
+type interpolator struct {
+	vars    map[string]interface{}
+	missing map[string]struct{}
+}
+
+func (i interpolator) interpolate(node interface{}) (interface{}, error) {
+	switch typedNode := node.(type) {
+	case map[interface{}]interface{}:
+		result := map[interface{}]interface{}{}
+		for key, value := range typedNode {
+			interpolatedKey, err := i.interpolate(key)
+			if err != nil {
+				return nil, err
+			}
+
+			interpolatedValue, err := i.interpolate(value)
+			if err != nil {
+				return nil, err
+			}
+
+			result[interpolatedKey] = interpolatedValue
+		}
+
+		return result, nil
+	case []interface{}:
+		result := []interface{}{}
+		for _, value := range typedNode {
+			interpolatedValue, err := i.interpolate(value)
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, interpolatedValue)
+		}
+
+		return result, nil
+	case string:
+		return i.interpolateString(typedNode)
+	default:
+		return node, nil
+	}
+}
+
+func (i interpolator) interpolateString(value string) (interface{}, error) {
+	matches := variableRegexp.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return value, nil
+	}
+
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(value) {
+		name := variableName(value[matches[0][2]:matches[0][3]])
+
+		found, ok := i.lookup(name)
+		if !ok {
+			return value, nil
+		}
+
+		return found, nil
+	}
+
+	var err error
+	interpolated := variableRegexp.ReplaceAllStringFunc(value, func(placeholder string) string {
+		name := variableName(placeholder[2 : len(placeholder)-2])
+
+		found, ok := i.lookup(name)
+		if !ok {
+			return placeholder
+		}
+
+		switch found.(type) {
+		case string, int, int64, uint64, float64, bool:
+			return fmt.Sprintf("%v", found)
+		default:
+			err = fmt.Errorf("Invalid type '%T' for value '%v' and variable '%s'. Supported types for interpolation within a string are integers, floats, booleans and strings", found, found, name)
+			return placeholder
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return interpolated, nil
+}
+
+func (i interpolator) lookup(name string) (interface{}, bool) {
+	segments := strings.Split(name, ".")
+
+	value, ok := i.vars[segments[0]]
+	if !ok {
+		i.missing[name] = struct{}{}
+		return nil, false
+	}
+
+	for _, segment := range segments[1:] {
+		switch typedValue := value.(type) {
+		case map[interface{}]interface{}:
+			value, ok = typedValue[segment]
+		case map[string]interface{}:
+			value, ok = typedValue[segment]
+		default:
+			ok = false
+		}
+
+		if !ok {
+			i.missing[name] = struct{}{}
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+func variableName(name string) string {
+	return strings.TrimPrefix(name, "!")
+}
@@ -0,0 +1,149 @@
+package ops_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+	"github.com/pivotal-cf-experimental/gomegamatchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Interpolate", func() {
+	var manifest string
+
+	BeforeEach(func() {
+		manifest = `
+---
+name: ((deployment_name))
+instance_groups:
+- name: consul
+  azs: ((azs))
+  properties:
+    consul:
+      agent:
+        domain: ((consul_domain))
+        datacenter: dc-((datacenter_id))
+      ca_cert: ((consul_tls.ca))
+      agent_key: ((!consul_tls.private_key))`
+	})
+
+	It("returns a manifest with whole-value, inline and sub-key variables replaced", func() {
+		interpolatedManifest, err := ops.Interpolate(manifest, ops.InterpolateOptions{
+			Vars: map[string]interface{}{
+				"deployment_name": "some-name",
+				"azs":             []string{"z1", "z2"},
+				"consul_domain":   "cf.internal",
+				"datacenter_id":   1,
+				"consul_tls": map[string]interface{}{
+					"ca":          "some-ca",
+					"private_key": "some-private-key",
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(interpolatedManifest).To(gomegamatchers.MatchYAML(`
+---
+name: some-name
+instance_groups:
+- name: consul
+  azs: [z1, z2]
+  properties:
+    consul:
+      agent:
+        domain: cf.internal
+        datacenter: dc-1
+      ca_cert: some-ca
+      agent_key: some-private-key`))
+	})
+
+	It("leaves missing variables in place when not strict", func() {
+		interpolatedManifest, err := ops.Interpolate("name: ((deployment_name))\nazs: ((azs))", ops.InterpolateOptions{
+			Vars: map[string]interface{}{
+				"deployment_name": "some-name",
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(interpolatedManifest).To(gomegamatchers.MatchYAML("name: some-name\nazs: ((azs))"))
+	})
+
+	Context("when vars files are provided", func() {
+		var varsFilePath string
+
+		BeforeEach(func() {
+			file, err := ioutil.TempFile("", "vars-file")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = file.WriteString("deployment_name: some-file-name\nconsul_domain: cf.internal")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+
+			varsFilePath = file.Name()
+		})
+
+		AfterEach(func() {
+			Expect(os.Remove(varsFilePath)).To(Succeed())
+		})
+
+		It("uses the vars from the files, with vars taking precedence", func() {
+			interpolatedManifest, err := ops.Interpolate("name: ((deployment_name))\ndomain: ((consul_domain))", ops.InterpolateOptions{
+				VarsFiles: []string{varsFilePath},
+				Vars: map[string]interface{}{
+					"deployment_name": "some-name",
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(interpolatedManifest).To(gomegamatchers.MatchYAML("name: some-name\ndomain: cf.internal"))
+		})
+	})
+
+	Context("failure cases", func() {
+		Context("when strict and variables are missing", func() {
+			It("returns an error listing every missing variable", func() {
+				_, err := ops.Interpolate(manifest, ops.InterpolateOptions{
+					Strict: true,
+					Vars: map[string]interface{}{
+						"deployment_name": "some-name",
+						"consul_tls": map[string]interface{}{
+							"ca": "some-ca",
+						},
+					},
+				})
+				Expect(err).To(MatchError("Expected to find variables: azs, consul_domain, consul_tls.private_key, datacenter_id"))
+				Expect(err).To(BeAssignableToTypeOf(ops.MissingVariablesError{}))
+			})
+		})
+
+		Context("when an inline variable is not a scalar", func() {
+			It("returns an error", func() {
+				_, err := ops.Interpolate("name: some-((azs))", ops.InterpolateOptions{
+					Vars: map[string]interface{}{
+						"azs": []string{"z1"},
+					},
+				})
+				Expect(err).To(MatchError("Invalid type '[]string' for value '[z1]' and variable 'azs'. Supported types for interpolation within a string are integers, floats, booleans and strings"))
+			})
+		})
+
+		Context("when a vars file does not exist", func() {
+			It("returns an error", func() {
+				_, err := ops.Interpolate(manifest, ops.InterpolateOptions{
+					VarsFiles: []string{"/some/missing/vars-file.yml"},
+				})
+				Expect(err).To(MatchError("open /some/missing/vars-file.yml: no such file or directory"))
+			})
+		})
+
+		Context("when the manifest yaml is invalid", func() {
+			It("returns an error", func() {
+				_, err := ops.Interpolate("%%%", ops.InterpolateOptions{})
+				Expect(err).To(MatchError("yaml: could not find expected directive name"))
+			})
+		})
+	})
+})
@@ -0,0 +1,27 @@
+package ops
+
+// Invert returns the ops that undo ops when they are applied to the manifest
+// that ops were applied to. Values that ops replaced or removed are put back
+// and anything they added is removed again.
+func Invert(manifest string, ops []Op) ([]Op, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return document.Invert(ops)
+}
+
+func (d Document) Invert(ops []Op) ([]Op, error) {
+	patched, err := d.ApplyOps(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	inverseOps := diffValues(NewPath(), patched.doc, d.doc)
+	for i := range inverseOps {
+		inverseOps[i].Value = copyValue(inverseOps[i].Value)
+	}
+
+	return inverseOps, nil
+}
@@ -0,0 +1,144 @@
+package ops_test
+
+import (
+	"github.com/pivotal-cf-experimental/destiny/ops"
+	"github.com/pivotal-cf-experimental/gomegamatchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Invert", func() {
+	var manifest string
+
+	BeforeEach(func() {
+		manifest = `name: some-name
+stemcells:
+- alias: default
+  os: ubuntu-trusty
+instance_groups:
+- name: consul
+  azs: [z1]
+  jobs:
+  - name: consul_agent
+    release: consul
+    properties:
+      consul:
+        require_ssl: false
+- name: testconsumer
+  azs: [z1]
+  jobs:
+  - name: consul_agent
+    release: consul
+  - name: consul-test-consumer
+    release: consul`
+	})
+
+	It("returns ops that restore replaced and removed values and remove appended ones", func() {
+		experimentalOps := []ops.Op{
+			{Type: "replace", Path: "/name", Value: "some-other-name"},
+			{Type: "replace", Path: "/instance_groups/name=consul/vm_extensions?", Value: []string{"50GB_ephemeral_disk"}},
+			{Type: "remove", Path: "/instance_groups/name=consul/jobs/name=consul_agent/properties"},
+			{Type: "replace", Path: "/stemcells/-", Value: map[string]string{"alias": "windows", "os": "windows2012R2"}},
+		}
+
+		inverseOps, err := ops.Invert(manifest, experimentalOps)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(inverseOps).To(Equal([]ops.Op{
+			{Type: "remove", Path: "/instance_groups/name=consul/vm_extensions"},
+			{
+				Type: "replace",
+				Path: "/instance_groups/name=consul/jobs/name=consul_agent/properties?",
+				Value: map[interface{}]interface{}{
+					"consul": map[interface{}]interface{}{"require_ssl": false},
+				},
+			},
+			{Type: "replace", Path: "/name", Value: "some-name"},
+			{Type: "remove", Path: "/stemcells/1"},
+		}))
+
+		modifiedManifest, err := ops.ApplyOps(manifest, experimentalOps)
+		Expect(err).NotTo(HaveOccurred())
+
+		restoredManifest, err := ops.ApplyOps(modifiedManifest, inverseOps)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(restoredManifest).To(gomegamatchers.MatchYAML(manifest))
+	})
+
+	It("restores a removed map key", func() {
+		removeOps := []ops.Op{{Type: "remove", Path: "/props"}}
+
+		modifiedManifest, err := ops.ApplyOps("props: {x: 1}", removeOps)
+		Expect(err).NotTo(HaveOccurred())
+
+		inverseOps, err := ops.Invert("props: {x: 1}", removeOps)
+		Expect(err).NotTo(HaveOccurred())
+
+		restoredManifest, err := ops.ApplyOps(modifiedManifest, inverseOps)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(restoredManifest).To(gomegamatchers.MatchYAML("props: {x: 1}"))
+	})
+
+	It("restores keys that contain a ':' or '=' or read as an index", func() {
+		original := "props:\n  a:b: 1\n  a=b: 1\n  '8080': 1\n  other: 1"
+
+		for _, invertedOps := range [][]ops.Op{
+			{{Type: "replace", Path: "/props/a~7b", Value: 2}},
+			{{Type: "replace", Path: "/props", Value: map[interface{}]interface{}{"a=b": 2, "other": 1}}},
+			{{Type: "replace", Path: "/props", Value: map[interface{}]interface{}{"a:b": 1, "a=b": 1, "8080": 2, "other": 1}}},
+		} {
+			modifiedManifest, err := ops.ApplyOps(original, invertedOps)
+			Expect(err).NotTo(HaveOccurred())
+
+			inverseOps, err := ops.Invert(original, invertedOps)
+			Expect(err).NotTo(HaveOccurred())
+
+			restoredManifest, err := ops.ApplyOps(modifiedManifest, inverseOps)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(restoredManifest).To(gomegamatchers.MatchYAML(original))
+		}
+	})
+
+	It("round trips the experimental ops back to the original manifest", func() {
+		experimentalOps := []ops.Op{
+			{Type: "replace", Path: "/instance_groups/*/azs", Value: []string{"z1", "z2"}},
+			{Type: "replace", Path: "/instance_groups/name=consul/jobs/name=consul_agent/properties/consul/require_ssl", Value: true},
+			{Type: "replace", Path: "/instance_groups/name=testconsumer/jobs/name=consul_agent/name", Value: "consul_agent_windows"},
+			{Type: "remove", Path: "/instance_groups/name=testconsumer/jobs/name=consul-test-consumer"},
+			{Type: "merge", Path: "/instance_groups/name=consul/jobs/name=consul_agent/properties/consul", Value: map[string]interface{}{"domain": "cf.internal"}},
+		}
+
+		modifiedManifest, err := ops.ApplyOps(manifest, experimentalOps)
+		Expect(err).NotTo(HaveOccurred())
+
+		inverseOps, err := ops.Invert(manifest, experimentalOps)
+		Expect(err).NotTo(HaveOccurred())
+
+		restoredManifest, err := ops.ApplyOps(modifiedManifest, inverseOps)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(restoredManifest).To(gomegamatchers.MatchYAML(manifest))
+	})
+
+	Context("failure cases", func() {
+		Context("when the ops cannot be applied", func() {
+			It("returns an error", func() {
+				_, err := ops.Invert(manifest, []ops.Op{
+					{Type: "remove", Path: "/director_uuid"},
+				})
+				Expect(err).To(MatchError(ContainSubstring("op 0 (remove '/director_uuid') failed")))
+			})
+		})
+
+		Context("when the manifest yaml is invalid", func() {
+			It("returns an error", func() {
+				_, err := ops.Invert("%%%", []ops.Op{})
+				Expect(err).To(MatchError("yaml: could not find expected directive name"))
+			})
+		})
+	})
+})
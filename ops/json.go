@@ -0,0 +1,150 @@
+package ops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// isJSON reports whether a manifest may be a JSON object. Those are parsed
+// with encoding/json, since not every JSON document is one that yaml.v2
+// accepts. A YAML flow mapping starts with '{' as well, so a manifest that
+// encoding/json rejects is parsed as YAML instead.
+func isJSON(manifest string) bool {
+	return strings.HasPrefix(strings.TrimSpace(manifest), "{")
+}
+
+func parseJSON(manifest string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(manifest))
+	decoder.UseNumber()
+
+	var doc interface{}
+	err := decoder.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("manifest contains data after the JSON object")
+	}
+
+	return fromJSON(doc), nil
+}
+
+// fromJSON converts a decoded JSON value into the same shape that
+// yaml.Unmarshal produces.
+func fromJSON(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		converted := map[interface{}]interface{}{}
+		for key, child := range typedValue {
+			converted[key] = fromJSON(child)
+		}
+
+		return converted
+	case []interface{}:
+		converted := []interface{}{}
+		for _, child := range typedValue {
+			converted = append(converted, fromJSON(child))
+		}
+
+		return converted
+	case json.Number:
+		if i, err := strconv.Atoi(typedValue.String()); err == nil {
+			return i
+		}
+
+		if i, err := typedValue.Int64(); err == nil {
+			return i
+		}
+
+		if f, err := typedValue.Float64(); err == nil {
+			return f
+		}
+
+		// not tested
+		return typedValue.String()
+	default:
+		return value
+	}
+}
+
+// toJSON converts a value into one that encoding/json can encode. Values that
+// are not already a parsed tree, such as a Manifest, are converted through
+// YAML first so that their yaml tags decide the keys.
+func toJSON(value interface{}) (interface{}, error) {
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for key, child := range typedValue {
+			convertedChild, err := toJSON(child)
+			if err != nil {
+				return nil, err
+			}
+
+			converted[fmt.Sprint(key)] = convertedChild
+		}
+
+		return converted, nil
+	case []interface{}:
+		converted := []interface{}{}
+		for _, child := range typedValue {
+			convertedChild, err := toJSON(child)
+			if err != nil {
+				return nil, err
+			}
+
+			converted = append(converted, convertedChild)
+		}
+
+		return converted, nil
+	case nil, string, bool, int, int64, uint64, float64:
+		return value, nil
+	default:
+		contents, err := yaml.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		var doc interface{}
+		err = yaml.Unmarshal(contents, &doc)
+		if err != nil {
+			// not tested
+			return nil, err
+		}
+
+		return toJSON(doc)
+	}
+}
+
+// encodeJSON writes value as JSON. Keys are sorted, so the output for a given
+// manifest is always the same. Indented output uses two spaces.
+func encodeJSON(w io.Writer, value interface{}, indent bool) error {
+	jsonValue, err := toJSON(value)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if indent {
+		encoder.SetIndent("", "  ")
+	}
+
+	return encoder.Encode(jsonValue)
+}
+
+func marshalJSON(value interface{}, indent bool) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	err := encodeJSON(buffer, value, indent)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
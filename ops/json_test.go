@@ -0,0 +1,91 @@
+package ops_test
+
+import (
+	"github.com/pivotal-cf-experimental/destiny/ops"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON manifests", func() {
+	var manifest string
+
+	BeforeEach(func() {
+		manifest = `{
+	"name": "some-name",
+	"releases": [{"name": "consul", "version": "1.10", "url": "https:\/\/example.com\/consul"}],
+	"instance_groups": [
+		{"name": "consul", "azs": ["z1"], "instances": 3, "properties": {"timeout": 2.5, "max": 9007199254740993, "tag": "été"}}
+	]
+}`
+	})
+
+	It("applies ops to a JSON manifest", func() {
+		modifiedManifest, err := ops.ApplyOps(manifest, []ops.Op{
+			{Type: "replace", Path: "/instance_groups/name=consul/instances", Value: 5},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(modifiedManifest).To(Equal(`instance_groups:
+- azs:
+  - z1
+  instances: 5
+  name: consul
+  properties:
+    max: 9007199254740993
+    tag: été
+    timeout: 2.5
+name: some-name
+releases:
+- name: consul
+  url: https://example.com/consul
+  version: "1.10"`))
+	})
+
+	It("renders a JSON manifest back as JSON", func() {
+		modifiedManifest, err := ops.Renderer{Format: ops.CompactJSONOutput}.ApplyOp(manifest, ops.Op{
+			Type: "remove",
+			Path: "/instance_groups",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(modifiedManifest).To(Equal(`{"name":"some-name","releases":[{"name":"consul","url":"https://example.com/consul","version":"1.10"}]}`))
+	})
+
+	It("retrieves values from a JSON manifest", func() {
+		instanceGroups, err := ops.InstanceGroups(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instanceGroups[0].Instances).To(Equal(3))
+
+		releases, err := ops.Releases(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(releases[0].Version).To(Equal("1.10"))
+	})
+
+	It("parses a YAML flow mapping as YAML", func() {
+		manifest := `{name: some-name, instance_groups: [{name: consul, instances: 1}]}`
+
+		name, _, err := ops.FindString(manifest, "/name")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("some-name"))
+
+		modifiedManifest, err := ops.ApplyOp(manifest, ops.Op{Type: "replace", Path: "/instance_groups/name=consul/instances", Value: 3})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(modifiedManifest).To(Equal(`instance_groups:
+- instances: 3
+  name: consul
+name: some-name`))
+	})
+
+	Context("failure cases", func() {
+		It("returns an error when the JSON is malformed", func() {
+			_, err := ops.ApplyOps(`{"name": "some-name" "azs": []}`, []ops.Op{})
+			Expect(err).To(MatchError(ContainSubstring("invalid character '\"' after object key:value pair")))
+		})
+
+		It("returns an error when there is data after the JSON object", func() {
+			_, err := ops.ApplyOps(`{"name": "some-name"} {}`, []ops.Op{})
+			Expect(err).To(MatchError("manifest contains data after the JSON object"))
+		})
+	})
+})
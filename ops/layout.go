@@ -0,0 +1,290 @@
+package ops
+
+import (
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// layoutPatcher carries the changes made by a list of ops over to the node
+// tree of the original manifest. Subtrees whose values did not change keep
+// their original nodes, so their key order, comments and scalar styles are
+// written back out untouched.
+type layoutPatcher struct{}
+
+// applyLayout renders patchedDoc on top of the layout of manifest. The output
+// ends with a line break when manifest does, so that an unchanged manifest is
+// written back byte for byte.
+func applyLayout(manifest string, doc, patchedDoc interface{}) (string, error) {
+	var document yamlv3.Node
+	err := yamlv3.Unmarshal([]byte(manifest), &document)
+	if err != nil {
+		return "", err
+	}
+
+	patcher := layoutPatcher{}
+
+	if len(document.Content) == 0 {
+		root, err := patcher.newNode(patchedDoc)
+		if err != nil {
+			return "", err
+		}
+
+		document = yamlv3.Node{Kind: yamlv3.DocumentNode, Content: []*yamlv3.Node{root}}
+	} else {
+		root, err := patcher.reconcile(document.Content[0], doc, patchedDoc)
+		if err != nil {
+			return "", err
+		}
+
+		document.Content = []*yamlv3.Node{root}
+	}
+
+	output, err := encodeDocument(manifest, &document)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasSuffix(manifest, "\n") && !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+
+	return output, nil
+}
+
+func (p layoutPatcher) reconcile(node *yamlv3.Node, value, patchedValue interface{}) (*yamlv3.Node, error) {
+	if reflect.DeepEqual(value, patchedValue) {
+		return node, nil
+	}
+
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		if typedPatchedValue, ok := patchedValue.(map[interface{}]interface{}); ok && node.Kind == yamlv3.MappingNode {
+			return p.reconcileMapping(node, typedValue, typedPatchedValue)
+		}
+	case []interface{}:
+		if typedPatchedValue, ok := patchedValue.([]interface{}); ok && node.Kind == yamlv3.SequenceNode {
+			return p.reconcileSequence(node, typedValue, typedPatchedValue)
+		}
+	}
+
+	replacement, err := p.newNode(patchedValue)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.Kind == yamlv3.ScalarNode && replacement.Kind == yamlv3.ScalarNode && replacement.Tag == "!!str" {
+		multiline := strings.Contains(replacement.Value, "\n")
+		quoted := node.Style&(yamlv3.DoubleQuotedStyle|yamlv3.SingleQuotedStyle) != 0
+		block := node.Style&(yamlv3.LiteralStyle|yamlv3.FoldedStyle) != 0
+
+		if (quoted && !multiline) || (block && multiline) {
+			replacement.Style = node.Style &^ yamlv3.TaggedStyle
+		}
+	}
+
+	replacement.HeadComment = node.HeadComment
+	replacement.LineComment = node.LineComment
+	replacement.FootComment = node.FootComment
+
+	return replacement, nil
+}
+
+func (p layoutPatcher) reconcileMapping(node *yamlv3.Node, value, patchedValue map[interface{}]interface{}) (*yamlv3.Node, error) {
+	mapping := p.copyNode(node)
+
+	seen := map[interface{}]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, err := nodeKey(node.Content[i])
+		if err != nil {
+			return nil, err
+		}
+
+		patchedChild, ok := patchedValue[key]
+		if !ok {
+			continue
+		}
+		seen[key] = true
+
+		child, err := p.reconcile(node.Content[i+1], value[key], patchedChild)
+		if err != nil {
+			return nil, err
+		}
+
+		mapping.Content = append(mapping.Content, node.Content[i], child)
+	}
+
+	for _, key := range sortedMapKeys(patchedValue) {
+		if seen[key] {
+			continue
+		}
+
+		keyNode, err := p.newNode(key)
+		if err != nil {
+			return nil, err
+		}
+
+		child, err := p.newNode(patchedValue[key])
+		if err != nil {
+			return nil, err
+		}
+
+		mapping.Content = append(mapping.Content, keyNode, child)
+	}
+
+	return mapping, nil
+}
+
+func (p layoutPatcher) reconcileSequence(node *yamlv3.Node, value, patchedValue []interface{}) (*yamlv3.Node, error) {
+	sequence := p.copyNode(node)
+
+	used := map[int]bool{}
+	for i, patchedItem := range patchedValue {
+		index := matchSequenceItem(value, patchedItem, i, used)
+		if index < 0 {
+			item, err := p.newNode(patchedItem)
+			if err != nil {
+				return nil, err
+			}
+
+			sequence.Content = append(sequence.Content, item)
+			continue
+		}
+		used[index] = true
+
+		item, err := p.reconcile(node.Content[index], value[index], patchedItem)
+		if err != nil {
+			return nil, err
+		}
+
+		sequence.Content = append(sequence.Content, item)
+	}
+
+	return sequence, nil
+}
+
+// matchSequenceItem finds the original item that a patched item came from,
+// preferring an identical item, then an item with the same name, then the
+// item at the same index.
+func matchSequenceItem(items []interface{}, patchedItem interface{}, index int, used map[int]bool) int {
+	for i, item := range items {
+		if !used[i] && reflect.DeepEqual(item, patchedItem) {
+			return i
+		}
+	}
+
+	if patchedMap, ok := patchedItem.(map[interface{}]interface{}); ok {
+		if name, ok := patchedMap["name"].(string); ok {
+			for i, item := range items {
+				if itemMap, ok := item.(map[interface{}]interface{}); ok && !used[i] && itemMap["name"] == name {
+					return i
+				}
+			}
+		}
+	}
+
+	if index < len(items) && !used[index] {
+		return index
+	}
+
+	return -1
+}
+
+func (p layoutPatcher) copyNode(node *yamlv3.Node) *yamlv3.Node {
+	copied := *node
+	copied.Content = nil
+
+	return &copied
+}
+
+// newNode builds the node tree of a value that has no node in the source.
+// Node.Encode picks the style of each string, but its value is set again
+// afterwards because Node.Encode drops the leading line breaks of a string.
+func (p layoutPatcher) newNode(value interface{}) (*yamlv3.Node, error) {
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		mapping := &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map"}
+		for _, key := range sortedMapKeys(typedValue) {
+			keyNode, err := p.newNode(key)
+			if err != nil {
+				return nil, err
+			}
+
+			child, err := p.newNode(typedValue[key])
+			if err != nil {
+				return nil, err
+			}
+
+			mapping.Content = append(mapping.Content, keyNode, child)
+		}
+
+		return mapping, nil
+	case []interface{}:
+		sequence := &yamlv3.Node{Kind: yamlv3.SequenceNode, Tag: "!!seq"}
+		for _, item := range typedValue {
+			child, err := p.newNode(item)
+			if err != nil {
+				return nil, err
+			}
+
+			sequence.Content = append(sequence.Content, child)
+		}
+
+		return sequence, nil
+	}
+
+	node := &yamlv3.Node{}
+	err := node.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if typedValue, ok := value.(string); ok {
+		node.Value = typedValue
+	}
+
+	return node, nil
+}
+
+// encodeDocument writes a document node tree with a layoutEmitter over the
+// source the tree was parsed from. The line break that ends the last line is
+// dropped unless the document ends in a block scalar, whose content would
+// lose its own final line break with it.
+func encodeDocument(source string, document *yamlv3.Node) (string, error) {
+	emitter := newLayoutEmitter(source)
+
+	output, err := emitter.emitDocument(document)
+	if err != nil {
+		return "", err
+	}
+
+	if !emitter.endsInBlockScalar {
+		output = strings.TrimSuffix(output, "\n")
+	}
+
+	return output, nil
+}
+
+func nodeKey(node *yamlv3.Node) (interface{}, error) {
+	if node.Kind != yamlv3.ScalarNode {
+		var key interface{}
+		err := node.Decode(&key)
+		return key, err
+	}
+
+	contents, err := yamlv3.Marshal(node)
+	if err != nil {
+		// not tested
+		return nil, err
+	}
+
+	var key interface{}
+	err = yaml.Unmarshal(contents, &key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
@@ -0,0 +1,154 @@
+package ops
+
+import (
+	"fmt"
+	"sort"
+)
+
+type LinkProvider struct {
+	InstanceGroup string
+	Job           string
+	Name          string
+	Alias         string
+}
+
+type LinkConsumer struct {
+	InstanceGroup string
+	Job           string
+	Name          string
+	From          string
+	Deployment    string
+	Disabled      bool
+	Providers     []LinkProvider
+}
+
+type LinkGraph struct {
+	Providers []LinkProvider
+	Consumers []LinkConsumer
+}
+
+// ResolveLinks builds the graph of link providers and consumers declared in
+// the manifest. Providers are known by their "as" alias, or by their link name
+// when no alias is given. Consumers with a "from" are matched against aliases,
+// consumers without one are matched implicitly by link name, and consumers set
+// to nil or null are left unresolved as deliberately disabled. Consumers that point at
+// another deployment are not resolved.
+func ResolveLinks(manifest string) (LinkGraph, error) {
+	manifestStruct, err := ParseManifest(manifest)
+	if err != nil {
+		return LinkGraph{}, err
+	}
+
+	graph := LinkGraph{
+		Providers: []LinkProvider{},
+		Consumers: []LinkConsumer{},
+	}
+
+	for _, instanceGroup := range manifestStruct.InstanceGroups {
+		for _, job := range instanceGroup.Jobs {
+			for _, name := range sortedKeys(job.Provides) {
+				alias := job.Provides[name].As
+				if alias == "" {
+					alias = name
+				}
+
+				graph.Providers = append(graph.Providers, LinkProvider{
+					InstanceGroup: instanceGroup.Name,
+					Job:           job.Name,
+					Name:          name,
+					Alias:         alias,
+				})
+			}
+		}
+	}
+
+	problems := []ValidationProblem{}
+
+	aliases := map[string][]LinkProvider{}
+	for _, provider := range graph.Providers {
+		aliases[provider.Alias] = append(aliases[provider.Alias], provider)
+		if len(aliases[provider.Alias]) == 2 {
+			problems = append(problems, ValidationProblem{
+				Path:    linkPath(provider.InstanceGroup, provider.Job, "provides", provider.Name),
+				Message: fmt.Sprintf("link alias '%s' is already provided by %s/%s", provider.Alias, aliases[provider.Alias][0].InstanceGroup, aliases[provider.Alias][0].Job),
+			})
+		}
+	}
+
+	for _, instanceGroup := range manifestStruct.InstanceGroups {
+		for _, job := range instanceGroup.Jobs {
+			for _, name := range sortedKeys(job.Consumes) {
+				link := job.Consumes[name]
+				consumer := LinkConsumer{
+					InstanceGroup: instanceGroup.Name,
+					Job:           job.Name,
+					Name:          name,
+					From:          link.From,
+					Deployment:    link.Deployment,
+					Disabled:      link.Disabled,
+					Providers:     []LinkProvider{},
+				}
+
+				path := linkPath(instanceGroup.Name, job.Name, "consumes", name)
+				external := consumer.Deployment != "" && consumer.Deployment != manifestStruct.Name
+
+				if consumer.Disabled || external {
+					graph.Consumers = append(graph.Consumers, consumer)
+					continue
+				}
+
+				if consumer.From != "" {
+					consumer.Providers = append(consumer.Providers, aliases[consumer.From]...)
+					if len(consumer.Providers) == 0 {
+						problems = append(problems, ValidationProblem{
+							Path:    path + "/from",
+							Message: fmt.Sprintf("no job provides a link as '%s'", consumer.From),
+						})
+					}
+				} else {
+					for _, provider := range graph.Providers {
+						if provider.Name == name {
+							consumer.Providers = append(consumer.Providers, provider)
+						}
+					}
+
+					if len(consumer.Providers) > 1 {
+						problems = append(problems, ValidationProblem{
+							Path:    path,
+							Message: fmt.Sprintf("implicit link '%s' is ambiguous, found %d providers", name, len(consumer.Providers)),
+						})
+					}
+				}
+
+				graph.Consumers = append(graph.Consumers, consumer)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return graph, ValidationError{Problems: problems}
+	}
+
+	return graph, nil
+}
+
+func linkPath(instanceGroup, job, direction, name string) string {
+	return fmt.Sprintf("/instance_groups/name=%s/jobs/name=%s/%s/%s", instanceGroup, job, direction, name)
+}
+
+func sortedKeys(links interface{}) []string {
+	keys := []string{}
+	switch typedLinks := links.(type) {
+	case map[string]ProvidesLink:
+		for key := range typedLinks {
+			keys = append(keys, key)
+		}
+	case map[string]ConsumesLink:
+		for key := range typedLinks {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,133 @@
+package ops_test
+
+import (
+	"io/ioutil"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ResolveLinks", func() {
+	It("returns the link graph of a manifest", func() {
+		graph, err := ops.ResolveLinks(`
+name: some-name
+instance_groups:
+- name: consul
+  jobs:
+  - name: consul_agent
+    consumes:
+      consul_common: { from: common_link }
+      consul_server: { from: server_link }
+    provides:
+      consul_common: { as: common_link }
+      consul_server: { as: server_link }
+- name: testconsumer
+  jobs:
+  - name: consul_agent
+    consumes:
+      consul_common: { from: common_link }
+      consul_server: nil
+  - name: etcd
+    consumes:
+      etcd: {}
+      cf_etcd: { deployment: cf, from: etcd_server }
+    provides:
+      etcd: {}`)
+		Expect(err).NotTo(HaveOccurred())
+
+		commonLink := ops.LinkProvider{InstanceGroup: "consul", Job: "consul_agent", Name: "consul_common", Alias: "common_link"}
+		serverLink := ops.LinkProvider{InstanceGroup: "consul", Job: "consul_agent", Name: "consul_server", Alias: "server_link"}
+		etcdLink := ops.LinkProvider{InstanceGroup: "testconsumer", Job: "etcd", Name: "etcd", Alias: "etcd"}
+
+		Expect(graph.Providers).To(Equal([]ops.LinkProvider{commonLink, serverLink, etcdLink}))
+		Expect(graph.Consumers).To(Equal([]ops.LinkConsumer{
+			{InstanceGroup: "consul", Job: "consul_agent", Name: "consul_common", From: "common_link", Providers: []ops.LinkProvider{commonLink}},
+			{InstanceGroup: "consul", Job: "consul_agent", Name: "consul_server", From: "server_link", Providers: []ops.LinkProvider{serverLink}},
+			{InstanceGroup: "testconsumer", Job: "consul_agent", Name: "consul_common", From: "common_link", Providers: []ops.LinkProvider{commonLink}},
+			{InstanceGroup: "testconsumer", Job: "consul_agent", Name: "consul_server", Disabled: true, Providers: []ops.LinkProvider{}},
+			{InstanceGroup: "testconsumer", Job: "etcd", Name: "cf_etcd", From: "etcd_server", Deployment: "cf", Providers: []ops.LinkProvider{}},
+			{InstanceGroup: "testconsumer", Job: "etcd", Name: "etcd", Providers: []ops.LinkProvider{etcdLink}},
+		}))
+	})
+
+	It("leaves null consumers disabled instead of resolving them implicitly", func() {
+		graph, err := ops.ResolveLinks(`
+name: some-name
+instance_groups:
+- name: consul
+  jobs:
+  - name: consul_agent
+    provides:
+      x: {as: consul_x}
+- name: etcd
+  jobs:
+  - name: etcd
+    provides:
+      x: {as: etcd_x}
+- name: testconsumer
+  jobs:
+  - name: consul_agent
+    consumes: {x: ~}`)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(graph.Consumers).To(Equal([]ops.LinkConsumer{
+			{InstanceGroup: "testconsumer", Job: "consul_agent", Name: "x", Disabled: true, Providers: []ops.LinkProvider{}},
+		}))
+	})
+
+	It("resolves the links in the generated manifests", func() {
+		for _, fixture := range []string{
+			"../consul/fixtures/consul_manifest_v2.yml",
+			"../consul/fixtures/consul_manifest_v2_windows.yml",
+			"../etcd/fixtures/etcd_manifest_v2_tls.yml",
+			"../etcd/fixtures/etcd_manifest_v2_non_tls.yml",
+			"../turbulence/fixtures/turbulence_manifest_v2.yml",
+		} {
+			contents, err := ioutil.ReadFile(fixture)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = ops.ResolveLinks(string(contents))
+			Expect(err).NotTo(HaveOccurred(), fixture)
+		}
+	})
+
+	Context("failure cases", func() {
+		It("returns every dangling, duplicate and ambiguous link", func() {
+			graph, err := ops.ResolveLinks(`
+instance_groups:
+- name: consul
+  jobs:
+  - name: consul_agent
+    provides:
+      consul_common: { as: common_link }
+- name: etcd
+  jobs:
+  - name: etcd
+    consumes:
+      etcd: { from: etcd_server }
+      consul_common: {}
+    provides:
+      etcd: { as: common_link }
+- name: testconsumer
+  jobs:
+  - name: consul_agent
+    provides:
+      consul_common: {}`)
+			Expect(err).To(MatchError(`manifest is invalid:
+- /instance_groups/name=etcd/jobs/name=etcd/provides/etcd: link alias 'common_link' is already provided by consul/consul_agent
+- /instance_groups/name=etcd/jobs/name=etcd/consumes/consul_common: implicit link 'consul_common' is ambiguous, found 2 providers
+- /instance_groups/name=etcd/jobs/name=etcd/consumes/etcd/from: no job provides a link as 'etcd_server'`))
+
+			Expect(graph.Consumers).To(HaveLen(2))
+		})
+
+		Context("when the manifest yaml is invalid", func() {
+			It("returns an error", func() {
+				_, err := ops.ResolveLinks("%%%")
+				Expect(err).To(MatchError("yaml: could not find expected directive name"))
+			})
+		})
+	})
+})
@@ -0,0 +1,239 @@
+package ops
+
+import yaml "gopkg.in/yaml.v2"
+
+const disabledLink = "nil"
+
+type Manifest struct {
+	Name           string                 `yaml:"name"`
+	Releases       []Release              `yaml:"releases,omitempty"`
+	Stemcells      []Stemcell             `yaml:"stemcells,omitempty"`
+	Update         *Update                `yaml:"update,omitempty"`
+	InstanceGroups []InstanceGroup        `yaml:"instance_groups,omitempty"`
+	Variables      []Variable             `yaml:"variables,omitempty"`
+	Extra          map[string]interface{} `yaml:",inline"`
+}
+
+type Release struct {
+	Name    string                 `yaml:"name"`
+	Version string                 `yaml:"version,omitempty"`
+	URL     string                 `yaml:"url,omitempty"`
+	SHA1    string                 `yaml:"sha1,omitempty"`
+	Extra   map[string]interface{} `yaml:",inline"`
+}
+
+type Stemcell struct {
+	Alias   string                 `yaml:"alias,omitempty"`
+	OS      string                 `yaml:"os,omitempty"`
+	Name    string                 `yaml:"name,omitempty"`
+	Version string                 `yaml:"version,omitempty"`
+	Extra   map[string]interface{} `yaml:",inline"`
+}
+
+type Update struct {
+	Canaries        *int                   `yaml:"canaries,omitempty"`
+	CanaryWatchTime interface{}            `yaml:"canary_watch_time,omitempty"`
+	UpdateWatchTime interface{}            `yaml:"update_watch_time,omitempty"`
+	MaxInFlight     interface{}            `yaml:"max_in_flight,omitempty"`
+	Serial          *bool                  `yaml:"serial,omitempty"`
+	Extra           map[string]interface{} `yaml:",inline"`
+}
+
+type InstanceGroup struct {
+	Name               string                 `yaml:"name"`
+	AZs                []string               `yaml:"azs,omitempty"`
+	Instances          int                    `yaml:"instances,omitempty"`
+	Lifecycle          string                 `yaml:"lifecycle,omitempty"`
+	Jobs               []Job                  `yaml:"jobs,omitempty"`
+	VMType             string                 `yaml:"vm_type,omitempty"`
+	VMExtensions       []string               `yaml:"vm_extensions,omitempty"`
+	Stemcell           string                 `yaml:"stemcell,omitempty"`
+	PersistentDiskType string                 `yaml:"persistent_disk_type,omitempty"`
+	Networks           []Network              `yaml:"networks,omitempty"`
+	Update             *Update                `yaml:"update,omitempty"`
+	Properties         map[string]interface{} `yaml:"properties,omitempty"`
+	Extra              map[string]interface{} `yaml:",inline"`
+
+	zeroInstances bool
+}
+
+type Job struct {
+	Name       string                  `yaml:"name"`
+	Release    string                  `yaml:"release,omitempty"`
+	Consumes   map[string]ConsumesLink `yaml:"consumes,omitempty"`
+	Provides   map[string]ProvidesLink `yaml:"provides,omitempty"`
+	Properties map[string]interface{}  `yaml:"properties,omitempty"`
+	Extra      map[string]interface{}  `yaml:",inline"`
+}
+
+type ConsumesLink struct {
+	From        string                 `yaml:"from,omitempty"`
+	Deployment  string                 `yaml:"deployment,omitempty"`
+	Network     string                 `yaml:"network,omitempty"`
+	IPAddresses *bool                  `yaml:"ip_addresses,omitempty"`
+	Disabled    bool                   `yaml:"-"`
+	Extra       map[string]interface{} `yaml:",inline"`
+
+	null bool
+}
+
+type ProvidesLink struct {
+	As     string                 `yaml:"as,omitempty"`
+	Shared *bool                  `yaml:"shared,omitempty"`
+	Extra  map[string]interface{} `yaml:",inline"`
+}
+
+type Network struct {
+	Name      string                 `yaml:"name"`
+	StaticIPs []string               `yaml:"static_ips,omitempty"`
+	Default   []string               `yaml:"default,omitempty"`
+	Extra     map[string]interface{} `yaml:",inline"`
+}
+
+type instanceGroup InstanceGroup
+
+// UnmarshalYAML remembers whether the manifest wrote instances: 0, so that
+// it is not dropped like a missing instances key when the instance group is
+// written back.
+func (g *InstanceGroup) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var typedInstanceGroup instanceGroup
+	err := unmarshal(&typedInstanceGroup)
+	if err != nil {
+		return err
+	}
+
+	var instances struct {
+		Instances *int `yaml:"instances"`
+	}
+	err = unmarshal(&instances)
+	if err != nil {
+		// not tested
+		return err
+	}
+
+	typedInstanceGroup.zeroInstances = instances.Instances != nil && *instances.Instances == 0
+
+	*g = InstanceGroup(typedInstanceGroup)
+	return nil
+}
+
+// MarshalYAML writes instances: 0 back for an instance group that was parsed
+// with it.
+func (g InstanceGroup) MarshalYAML() (interface{}, error) {
+	if g.Instances != 0 || !g.zeroInstances {
+		return instanceGroup(g), nil
+	}
+
+	contents, err := yaml.Marshal(instanceGroup(g))
+	if err != nil {
+		// not tested
+		return nil, err
+	}
+
+	var fields yaml.MapSlice
+	err = yaml.Unmarshal(contents, &fields)
+	if err != nil {
+		// not tested
+		return nil, err
+	}
+
+	position := 0
+	for i, field := range fields {
+		if field.Key == "name" || field.Key == "azs" {
+			position = i + 1
+		}
+	}
+
+	instances := yaml.MapSlice{{Key: "instances", Value: 0}}
+	return append(fields[:position], append(instances, fields[position:]...)...), nil
+}
+
+type job Job
+
+// UnmarshalYAML marks the consumes links that are null as disabled. yaml.v2
+// does not call ConsumesLink.UnmarshalYAML for a null value, so the job has
+// to find them.
+func (j *Job) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var typedJob job
+	err := unmarshal(&typedJob)
+	if err != nil {
+		return err
+	}
+
+	var links struct {
+		Consumes map[string]interface{} `yaml:"consumes"`
+	}
+	err = unmarshal(&links)
+	if err != nil {
+		// not tested
+		return err
+	}
+
+	for name, value := range links.Consumes {
+		if value == nil {
+			if typedJob.Consumes == nil {
+				// not tested
+				typedJob.Consumes = map[string]ConsumesLink{}
+			}
+
+			typedJob.Consumes[name] = ConsumesLink{Disabled: true, null: true}
+		}
+	}
+
+	*j = Job(typedJob)
+	return nil
+}
+
+type consumesLink ConsumesLink
+
+// UnmarshalYAML treats the literal string nil as an explicitly disabled link,
+// which is how BOSH manifests opt out of a link. Null links are handled by
+// Job.UnmarshalYAML.
+func (l *ConsumesLink) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value interface{}
+	err := unmarshal(&value)
+	if err != nil {
+		return err
+	}
+
+	if value == disabledLink {
+		*l = ConsumesLink{Disabled: true}
+		return nil
+	}
+
+	var link consumesLink
+	err = unmarshal(&link)
+	if err != nil {
+		return err
+	}
+
+	*l = ConsumesLink(link)
+	return nil
+}
+
+// MarshalYAML writes a disabled link back the way it was disabled, as null or
+// as nil.
+func (l ConsumesLink) MarshalYAML() (interface{}, error) {
+	switch {
+	case l.Disabled && l.null:
+		return nil, nil
+	case l.Disabled:
+		return disabledLink, nil
+	}
+
+	return consumesLink(l), nil
+}
+
+func ParseManifest(manifest string) (Manifest, error) {
+	var manifestStruct Manifest
+	err := yaml.Unmarshal([]byte(manifest), &manifestStruct)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	return manifestStruct, nil
+}
+
+func (m Manifest) Marshal() (string, error) {
+	return Renderer{}.MarshalManifest(m)
+}
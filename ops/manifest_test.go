@@ -0,0 +1,229 @@
+package ops_test
+
+import (
+	"io/ioutil"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+	"github.com/pivotal-cf-experimental/gomegamatchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manifest", func() {
+	Describe("ParseManifest", func() {
+		It("returns a typed manifest", func() {
+			manifest, err := ops.ParseManifest(`
+name: some-name
+director_uuid: some-director-uuid
+releases:
+- name: consul
+  version: latest
+stemcells:
+- alias: default
+  os: ubuntu-trusty
+  version: latest
+update:
+  canaries: 1
+  canary_watch_time: 1000-180000
+  max_in_flight: 50%
+  serial: false
+instance_groups:
+- name: consul
+  instances: 3
+  azs: [z1]
+  jobs:
+  - name: consul_agent
+    release: consul
+    consumes:
+      consul_common: { from: common_link }
+      consul_server: nil
+    provides:
+      consul_common: { as: common_link, shared: true }
+    properties:
+      some-property: some-value
+  vm_type: default
+  stemcell: default
+  persistent_disk_type: 1GB
+  networks:
+  - name: private
+    static_ips: [10.0.0.1]
+  migrated_from:
+  - name: consul_z1
+variables:
+- name: consul_ca
+  type: certificate
+  options:
+    is_ca: true
+    common_name: consulCA
+    duration: 365`)
+			Expect(err).NotTo(HaveOccurred())
+
+			canaries := 1
+			serial := false
+			shared := true
+			Expect(manifest).To(Equal(ops.Manifest{
+				Name: "some-name",
+				Releases: []ops.Release{
+					{Name: "consul", Version: "latest"},
+				},
+				Stemcells: []ops.Stemcell{
+					{Alias: "default", OS: "ubuntu-trusty", Version: "latest"},
+				},
+				Update: &ops.Update{
+					Canaries:        &canaries,
+					CanaryWatchTime: "1000-180000",
+					MaxInFlight:     "50%",
+					Serial:          &serial,
+				},
+				InstanceGroups: []ops.InstanceGroup{
+					{
+						Name:      "consul",
+						Instances: 3,
+						AZs:       []string{"z1"},
+						Jobs: []ops.Job{
+							{
+								Name:    "consul_agent",
+								Release: "consul",
+								Consumes: map[string]ops.ConsumesLink{
+									"consul_common": {From: "common_link"},
+									"consul_server": {Disabled: true},
+								},
+								Provides: map[string]ops.ProvidesLink{
+									"consul_common": {As: "common_link", Shared: &shared},
+								},
+								Properties: map[string]interface{}{
+									"some-property": "some-value",
+								},
+							},
+						},
+						VMType:             "default",
+						Stemcell:           "default",
+						PersistentDiskType: "1GB",
+						Networks: []ops.Network{
+							{Name: "private", StaticIPs: []string{"10.0.0.1"}},
+						},
+						Extra: map[string]interface{}{
+							"migrated_from": []interface{}{
+								map[interface{}]interface{}{"name": "consul_z1"},
+							},
+						},
+					},
+				},
+				Variables: []ops.Variable{
+					{
+						Name: "consul_ca",
+						Type: "certificate",
+						Options: ops.VariableOptions{
+							IsCA:       true,
+							CommonName: "consulCA",
+							Extra: map[string]interface{}{
+								"duration": 365,
+							},
+						},
+					},
+				},
+				Extra: map[string]interface{}{
+					"director_uuid": "some-director-uuid",
+				},
+			}))
+		})
+
+		Context("failure cases", func() {
+			Context("when the manifest yaml is invalid", func() {
+				It("returns an error", func() {
+					_, err := ops.ParseManifest("%%%")
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+				})
+			})
+		})
+	})
+
+	Describe("Marshal", func() {
+		It("round-trips the generated manifests without loss", func() {
+			for _, fixture := range []string{
+				"../consul/fixtures/consul_manifest_v2.yml",
+				"../consul/fixtures/consul_manifest_v2_windows.yml",
+				"../etcd/fixtures/etcd_manifest_v2_tls.yml",
+				"../etcd/fixtures/etcd_manifest_v2_non_tls.yml",
+				"../turbulence/fixtures/turbulence_manifest_v2.yml",
+			} {
+				contents, err := ioutil.ReadFile(fixture)
+				Expect(err).NotTo(HaveOccurred())
+
+				manifest, err := ops.ParseManifest(string(contents))
+				Expect(err).NotTo(HaveOccurred())
+
+				manifestYAML, err := manifest.Marshal()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(manifestYAML).To(gomegamatchers.MatchYAML(contents), fixture)
+			}
+		})
+
+		It("round-trips null links, zero or missing instances and jobs without a release", func() {
+			contents := `
+name: some-name
+instance_groups:
+- name: testconsumer
+  azs: [z1]
+  instances: 0
+  jobs:
+  - name: consul_agent
+    release: consul
+    consumes:
+      consul_server: ~
+      consul_client: nil
+      consul_common: {from: common_link}
+  - name: some-job
+- name: some-errand
+  lifecycle: errand`
+
+			manifest, err := ops.ParseManifest(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			consumes := manifest.InstanceGroups[0].Jobs[0].Consumes
+			Expect(consumes["consul_server"].Disabled).To(BeTrue())
+			Expect(consumes["consul_client"].Disabled).To(BeTrue())
+			Expect(consumes["consul_common"].Disabled).To(BeFalse())
+
+			manifestYAML, err := manifest.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifestYAML).To(gomegamatchers.MatchYAML(contents))
+		})
+
+		It("writes disabled links as nil", func() {
+			manifestYAML, err := ops.Manifest{
+				Name: "some-name",
+				InstanceGroups: []ops.InstanceGroup{
+					{
+						Name:      "testconsumer",
+						Instances: 1,
+						Jobs: []ops.Job{
+							{
+								Name:    "consul_agent",
+								Release: "consul",
+								Consumes: map[string]ops.ConsumesLink{
+									"consul_server": {Disabled: true},
+								},
+							},
+						},
+					},
+				},
+			}.Marshal()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifestYAML).To(gomegamatchers.MatchYAML(`
+name: some-name
+instance_groups:
+- name: testconsumer
+  instances: 1
+  jobs:
+  - name: consul_agent
+    release: consul
+    consumes:
+      consul_server: nil`))
+		})
+	})
+})
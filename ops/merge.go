@@ -0,0 +1,169 @@
+package ops
+
+import (
+	"reflect"
+
+	"github.com/cppforlife/go-patch/patch"
+)
+
+// ListMode decides how a merge op combines a list in its value with the list
+// already in the manifest.
+type ListMode string
+
+const (
+	// ListModeReplace replaces the existing list. It is the default.
+	ListModeReplace ListMode = "replace"
+
+	// ListModeAppend appends the new items to the existing list.
+	ListModeAppend ListMode = "append"
+
+	// ListModeMergeByName merges each new item into the existing item with the
+	// same name, and appends the items that have no match.
+	ListModeMergeByName ListMode = "name"
+)
+
+func (m ListMode) valid() bool {
+	switch m {
+	case "", ListModeReplace, ListModeAppend, ListModeMergeByName:
+		return true
+	default:
+		return false
+	}
+}
+
+// Merge returns an op that deep merges value into the map at path.
+func Merge(path Path, value interface{}) Op {
+	return Op{Type: "merge", Path: path.String(), Value: value, pathErr: path.Validate()}
+}
+
+// mergeOp deep merges a value into the one at its path. Where nothing exists
+// at the path yet, it behaves like a replace op, creating the missing parents
+// as well when createParents is set.
+type mergeOp struct {
+	path          patch.Pointer
+	value         interface{}
+	listMode      ListMode
+	createParents bool
+}
+
+func (op mergeOp) Apply(doc interface{}) (interface{}, error) {
+	existing, err := patch.FindOp{Path: op.path}.Apply(doc)
+	if err != nil {
+		switch opErrorCause(err) {
+		case MissingKey, MissingNameMatch:
+		default:
+			return nil, err
+		}
+
+		path := optionalLast(op.path)
+		if op.createParents {
+			path = optionalPointer(op.path)
+		}
+
+		// The error of the lookup names the op's own path rather than the
+		// optional one, and the first segment that is missing.
+		patchedDoc, replaceErr := patch.ReplaceOp{Path: path, Value: op.value}.Apply(doc)
+		if replaceErr != nil {
+			return nil, err
+		}
+
+		return patchedDoc, nil
+	}
+
+	return patch.ReplaceOp{Path: op.path, Value: mergeValues(existing, op.value, op.listMode)}.Apply(doc)
+}
+
+func mergeValues(existing, value interface{}, listMode ListMode) interface{} {
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		typedExisting, ok := existing.(map[interface{}]interface{})
+		if !ok {
+			return value
+		}
+
+		merged := map[interface{}]interface{}{}
+		for key, child := range typedExisting {
+			merged[key] = child
+		}
+
+		for key, child := range typedValue {
+			if existingChild, ok := merged[key]; ok {
+				merged[key] = mergeValues(existingChild, child, listMode)
+				continue
+			}
+
+			merged[key] = child
+		}
+
+		return merged
+	case []interface{}:
+		typedExisting, ok := existing.([]interface{})
+		if !ok {
+			return value
+		}
+
+		switch listMode {
+		case ListModeAppend:
+			return append(append([]interface{}{}, typedExisting...), typedValue...)
+		case ListModeMergeByName:
+			return mergeListsByName(typedExisting, typedValue, listMode)
+		default:
+			return value
+		}
+	default:
+		return value
+	}
+}
+
+func mergeListsByName(existing, value []interface{}, listMode ListMode) []interface{} {
+	merged := append([]interface{}{}, existing...)
+
+	for _, item := range value {
+		index := -1
+		if name, ok := itemName(item); ok {
+			for i, existingItem := range merged {
+				if existingName, ok := itemName(existingItem); ok && reflect.DeepEqual(existingName, name) {
+					index = i
+					break
+				}
+			}
+		}
+
+		if index < 0 {
+			merged = append(merged, item)
+			continue
+		}
+
+		merged[index] = mergeValues(merged[index], item, listMode)
+	}
+
+	return merged
+}
+
+func itemName(item interface{}) (interface{}, bool) {
+	typedItem, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	name, ok := typedItem["name"]
+	return name, ok
+}
+
+// optionalPointer marks every key segment of pointer optional, so that
+// go-patch creates the maps that are missing along the way. Name selectors stay
+// strict, a typo in a name must not append a new item.
+func optionalPointer(pointer patch.Pointer) patch.Pointer {
+	tokens := []patch.Token{}
+	for _, token := range pointer.Tokens() {
+		switch typedToken := token.(type) {
+		case patch.KeyToken:
+			typedToken.Optional = true
+			tokens = append(tokens, typedToken)
+		default:
+			tokens = append(tokens, token)
+		}
+	}
+
+	return patch.NewPointer(tokens)
+}
@@ -0,0 +1,169 @@
+package ops_test
+
+import (
+	"github.com/pivotal-cf-experimental/destiny/ops"
+	"github.com/pivotal-cf-experimental/gomegamatchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Merge", func() {
+	var (
+		manifest string
+		agent    ops.Path
+	)
+
+	BeforeEach(func() {
+		manifest = `instance_groups:
+- name: consul
+  properties:
+    consul:
+      agent:
+        domain: cf.internal
+        servers:
+          lan: [10.0.4.2]
+        services:
+        - name: router
+          check: {interval: 10s}`
+
+		agent = ops.NewPath().Key("instance_groups").Name("consul").Key("properties").Key("consul").Key("agent")
+	})
+
+	It("deep merges a map into the target", func() {
+		modifiedManifest, err := ops.ApplyOps(manifest, []ops.Op{
+			ops.Merge(agent, map[string]interface{}{
+				"log_level": "debug",
+				"servers": map[string]interface{}{
+					"wan": []string{"10.0.5.2"},
+				},
+			}),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(modifiedManifest).To(gomegamatchers.MatchYAML(`instance_groups:
+- name: consul
+  properties:
+    consul:
+      agent:
+        domain: cf.internal
+        log_level: debug
+        servers:
+          lan: [10.0.4.2]
+          wan: [10.0.5.2]
+        services:
+        - name: router
+          check: {interval: 10s}`))
+	})
+
+	It("replaces lists by default", func() {
+		modifiedManifest, err := ops.ApplyOps(manifest, []ops.Op{
+			ops.Merge(agent.Key("servers"), map[string]interface{}{"lan": []string{"10.0.4.3"}}),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		lan, _, err := ops.FindStringSlice(modifiedManifest, agent.Key("servers").Key("lan").String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lan).To(Equal([]string{"10.0.4.3"}))
+	})
+
+	It("appends to lists", func() {
+		op := ops.Merge(agent.Key("servers"), map[string]interface{}{"lan": []string{"10.0.4.3"}})
+		op.ListMode = ops.ListModeAppend
+
+		modifiedManifest, err := ops.ApplyOps(manifest, []ops.Op{op})
+		Expect(err).NotTo(HaveOccurred())
+
+		lan, _, err := ops.FindStringSlice(modifiedManifest, agent.Key("servers").Key("lan").String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lan).To(Equal([]string{"10.0.4.2", "10.0.4.3"}))
+	})
+
+	It("merges list items by name", func() {
+		op := ops.Merge(agent.Key("services"), []interface{}{
+			map[string]interface{}{"name": "router", "check": map[string]interface{}{"timeout": "5s"}},
+			map[string]interface{}{"name": "uaa"},
+		})
+		op.ListMode = ops.ListModeMergeByName
+
+		modifiedManifest, err := ops.ApplyOps(manifest, []ops.Op{op})
+		Expect(err).NotTo(HaveOccurred())
+
+		services, _ := ops.FindOp(modifiedManifest, agent.Key("services").String())
+		Expect(services).To(Equal([]interface{}{
+			map[interface{}]interface{}{"name": "router", "check": map[interface{}]interface{}{"interval": "10s", "timeout": "5s"}},
+			map[interface{}]interface{}{"name": "uaa"},
+		}))
+	})
+
+	It("sets the value when the target does not exist yet", func() {
+		modifiedManifest, err := ops.ApplyOps(manifest, []ops.Op{
+			ops.Merge(agent.Key("telemetry"), map[string]interface{}{"statsd_address": "127.0.0.1:8125"}),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		address, _, err := ops.FindString(modifiedManifest, agent.Key("telemetry").Key("statsd_address").String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(address).To(Equal("127.0.0.1:8125"))
+	})
+
+	It("creates missing parents when asked to", func() {
+		op := ops.Merge(ops.NewPath().Key("instance_groups").Name("consul").Key("properties").Key("etcd").Key("tls"), map[string]interface{}{"require_ssl": true})
+		op.CreateParents = true
+
+		modifiedManifest, err := ops.ApplyOps(manifest, []ops.Op{op})
+		Expect(err).NotTo(HaveOccurred())
+
+		requireSSL, _, err := ops.FindBool(modifiedManifest, "/instance_groups/name=consul/properties/etcd/tls/require_ssl")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requireSSL).To(BeTrue())
+	})
+
+	It("merges into every wildcard match", func() {
+		modifiedManifest, err := ops.ApplyOps(`instance_groups:
+- name: consul
+  properties: {a: 1}
+- name: etcd
+  properties: {b: 2}`, []ops.Op{
+			ops.Merge(ops.NewPath().Key("instance_groups").Wildcard().Key("properties"), map[string]interface{}{"c": 3}),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(modifiedManifest).To(gomegamatchers.MatchYAML(`instance_groups:
+- name: consul
+  properties: {a: 1, c: 3}
+- name: etcd
+  properties: {b: 2, c: 3}`))
+	})
+
+	Context("failure cases", func() {
+		Context("when a parent is missing", func() {
+			It("returns an error", func() {
+				_, err := ops.ApplyOps(manifest, []ops.Op{
+					ops.Merge(ops.NewPath().Key("instance_groups").Name("etcd").Key("properties"), map[string]interface{}{"a": 1}),
+				})
+				Expect(err).To(MatchError("op 0 (merge '/instance_groups/name=etcd/properties') failed: Expected to find exactly one matching array item for path '/instance_groups/name=etcd' but found 0 (parent at line 2, column 1): found names 'consul'"))
+			})
+		})
+
+		Context("when creating parents and no item matches a name", func() {
+			It("returns an error instead of adding an item", func() {
+				op := ops.Merge(ops.NewPath().Key("instance_groups").Name("consull").Key("properties"), map[string]interface{}{"a": 1})
+				op.CreateParents = true
+
+				_, err := ops.ApplyOps(manifest, []ops.Op{op})
+				Expect(err).To(MatchError("op 0 (merge '/instance_groups/name=consull/properties') failed: Expected to find exactly one matching array item for path '/instance_groups/name=consull' but found 0 (parent at line 2, column 1): found names 'consul', did you mean 'consul'?"))
+			})
+		})
+
+		Context("when the list mode is not supported", func() {
+			It("returns an error", func() {
+				op := ops.Merge(agent, map[string]interface{}{})
+				op.ListMode = "prepend"
+
+				_, err := ops.ApplyOps(manifest, []ops.Op{op})
+				Expect(err).To(MatchError("op 0 (merge '/instance_groups/name=consul/properties/consul/agent') failed: list mode prepend not supported by destiny"))
+			})
+		})
+	})
+})
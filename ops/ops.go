@@ -2,7 +2,6 @@ package ops
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/cppforlife/go-patch/patch"
 
@@ -10,73 +9,58 @@ import (
 )
 
 type Op struct {
-	Type  string      `yaml:"type"`
-	Path  string      `yaml:"path"`
-	Value interface{} `yaml:"value"`
+	Type          string      `yaml:"type"`
+	Path          string      `yaml:"path"`
+	Value         interface{} `yaml:"value"`
+	Absent        bool        `yaml:"absent,omitempty"`
+	If            *Condition  `yaml:"if,omitempty"`
+	ListMode      ListMode    `yaml:"list_mode,omitempty"`
+	CreateParents bool        `yaml:"create_parents,omitempty"`
+
+	// pathErr is set when the op was built from a Path with a segment that
+	// cannot be represented, and is returned when the op is applied.
+	pathErr error
 }
 
-var marshal = yaml.Marshal
+type ApplyOptions struct {
+	PreserveLayout bool
+}
 
 func ApplyOp(manifest string, op Op) (string, error) {
 	return ApplyOps(manifest, []Op{op})
 }
 
 func ApplyOps(manifest string, ops []Op) (string, error) {
-	var doc interface{}
-	err := yaml.Unmarshal([]byte(manifest), &doc)
-	if err != nil {
-		return "", err
-	}
-
-	goPatchOps := patch.Ops{}
-	for _, op := range ops {
-		goPatchOp, err := makeGoPatchOp(op)
-		if err != nil {
-			return "", err
-		}
-
-		goPatchOps = append(goPatchOps, goPatchOp)
-	}
+	return Renderer{}.ApplyOps(manifest, ops)
+}
 
-	doc, err = goPatchOps.Apply(doc)
-	if err != nil {
-		// not tested
-		return "", err
-	}
+// ApplyOpsWithReport applies ops like ApplyOps and also reports the ops that
+// were skipped because their condition did not hold, and every change that
+// each op made.
+func ApplyOpsWithReport(manifest string, ops []Op) (string, ApplyReport, error) {
+	return Renderer{}.ApplyOpsWithReport(manifest, ops)
+}
 
-	manifestYAML, err := marshal(doc)
-	if err != nil {
-		return "", err
-	}
+// ApplyOpsToDocument applies ops to one document of a "---" separated stream
+// and returns the whole stream.
+func ApplyOpsToDocument(manifest string, selector DocumentSelector, ops []Op) (string, error) {
+	return Renderer{}.ApplyOpsToDocument(manifest, selector, ops)
+}
 
-	return strings.Trim(string(manifestYAML), "\n"), nil
+// ApplyOpsWithOptions applies ops like ApplyOps. With PreserveLayout set, the
+// key order, comments and scalar styles of the original manifest are kept and
+// only the values changed by the ops are rewritten.
+func ApplyOpsWithOptions(manifest string, ops []Op, options ApplyOptions) (string, error) {
+	return Renderer{Options: options}.ApplyOps(manifest, ops)
 }
 
 func FindOp(manifest, path string) (interface{}, error) {
-	var doc interface{}
-	err := yaml.Unmarshal([]byte(manifest), &doc)
-	if err != nil {
-		return "", err
-	}
-
-	pointerPath, err := patch.NewPointerFromString(path)
+	document, err := ParseDocument(manifest)
 	if err != nil {
 		return "", err
 	}
 
-	goPatchOps := patch.Ops{
-		patch.FindOp{
-			Path: pointerPath,
-		},
-	}
-
-	doc, err = goPatchOps.Apply(doc)
-	if err != nil {
-		// not tested
-		return "", err
-	}
-
-	return doc, nil
+	return document.Find(path)
 }
 
 func makeGoPatchOp(op Op) (patch.Op, error) {
@@ -87,9 +71,14 @@ func makeGoPatchOp(op Op) (patch.Op, error) {
 			return nil, err
 		}
 
+		value, err := normalizeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+
 		return patch.ReplaceOp{
 			Path:  path,
-			Value: op.Value,
+			Value: value,
 		}, nil
 	case "remove":
 		path, err := patch.NewPointerFromString(op.Path)
@@ -100,7 +89,124 @@ func makeGoPatchOp(op Op) (patch.Op, error) {
 		return patch.RemoveOp{
 			Path: path,
 		}, nil
+	case "test":
+		path, err := patch.NewPointerFromString(op.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := normalizeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		return testOp{patch.TestOp{
+			Path:   path,
+			Value:  value,
+			Absent: op.Absent,
+		}}, nil
+	case "merge":
+		path, err := patch.NewPointerFromString(op.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !op.ListMode.valid() {
+			return nil, fmt.Errorf("list mode %s not supported by destiny", op.ListMode)
+		}
+
+		value, err := normalizeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		return mergeOp{
+			path:          path,
+			value:         value,
+			listMode:      op.ListMode,
+			createParents: op.CreateParents,
+		}, nil
 	default:
 		return nil, fmt.Errorf("op type %s not supported by destiny", op.Type)
 	}
 }
+
+// testOp applies go-patch's test op and describes a failed test in terms of
+// the path and value the op was written with. A name= selector that matches
+// no item also counts as absent.
+type testOp struct {
+	patch.TestOp
+}
+
+func (op testOp) Apply(doc interface{}) (interface{}, error) {
+	_, err := op.TestOp.Apply(doc)
+	if err == nil {
+		return doc, nil
+	}
+
+	found, findErr := patch.FindOp{Path: op.Path}.Apply(doc)
+
+	if op.Absent {
+		if findErr == nil {
+			return nil, fmt.Errorf("test op failed: expected '%s' to be absent but found '%v'", op.Path, found)
+		}
+
+		if isUnmatchedSelectorErr(findErr, op.Path) {
+			return doc, nil
+		}
+
+		return nil, fmt.Errorf("test op failed: expected '%s' to be absent: %w", op.Path, err)
+	}
+
+	if findErr != nil {
+		return nil, fmt.Errorf("test op failed: expected '%s' to be '%v': %w", op.Path, op.Value, err)
+	}
+
+	return nil, fmt.Errorf("test op failed: expected '%s' to be '%v' but found '%v'", op.Path, op.Value, found)
+}
+
+func isUnmatchedSelectorErr(err error, path patch.Pointer) bool {
+	typedErr, ok := err.(patch.OpMultipleMatchingIndexErr)
+	return ok && typedErr.Path.String() == path.String() && len(typedErr.Idxs) == 0
+}
+
+// copyValue deep copies a document, since go-patch modifies the maps and
+// arrays it is given in place.
+func copyValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		copied := map[interface{}]interface{}{}
+		for key, child := range typedValue {
+			copied[key] = copyValue(child)
+		}
+
+		return copied
+	case []interface{}:
+		copied := []interface{}{}
+		for _, child := range typedValue {
+			copied = append(copied, copyValue(child))
+		}
+
+		return copied
+	default:
+		return value
+	}
+}
+
+// normalizeValue converts a Go value into the same shape that yaml.Unmarshal
+// produces, so that it can be compared against values found in a manifest and
+// later ops can find their way into it.
+func normalizeValue(value interface{}) (interface{}, error) {
+	contents, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized interface{}
+	err = yaml.Unmarshal(contents, &normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
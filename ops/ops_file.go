@@ -0,0 +1,220 @@
+package ops
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cppforlife/go-patch/patch"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var opsFileKeys = map[string]bool{
+	"type":   true,
+	"path":   true,
+	"value":  true,
+	"absent": true,
+	"if":     true,
+
+	"list_mode":      true,
+	"create_parents": true,
+}
+
+var conditionKeys = map[string]bool{
+	"path":   true,
+	"value":  true,
+	"absent": true,
+}
+
+func LoadOpsFile(path string) ([]Op, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadOps(file, path)
+}
+
+func ReadOps(reader io.Reader, name string) ([]Op, error) {
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	var entries []map[interface{}]interface{}
+	err = yaml.Unmarshal(contents, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	ops := []Op{}
+	for i, entry := range entries {
+		op, err := parseOpsFileEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %s", name, i, err)
+		}
+
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+func ParseOps(contents string) ([]Op, error) {
+	return ReadOps(strings.NewReader(contents), "ops")
+}
+
+func LoadVarsFile(path string) (map[string]interface{}, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]interface{}{}
+	err = yaml.Unmarshal(contents, &vars)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return vars, nil
+}
+
+func parseOpsFileEntry(entry map[interface{}]interface{}) (Op, error) {
+	for key := range entry {
+		keyString, ok := key.(string)
+		if !ok || !opsFileKeys[keyString] {
+			return Op{}, fmt.Errorf("unknown key '%v'", key)
+		}
+	}
+
+	opType, ok := entry["type"].(string)
+	if !ok || opType == "" {
+		return Op{}, fmt.Errorf("missing or invalid type")
+	}
+
+	path, ok := entry["path"].(string)
+	if !ok || path == "" {
+		return Op{}, fmt.Errorf("missing or invalid path")
+	}
+
+	_, err := patch.NewPointerFromString(path)
+	if err != nil {
+		return Op{}, fmt.Errorf("invalid path '%s': %s", path, err)
+	}
+
+	op := Op{
+		Type:  opType,
+		Path:  path,
+		Value: entry["value"],
+	}
+
+	_, hasValue := entry["value"]
+	switch opType {
+	case "replace":
+		if !hasValue {
+			return Op{}, fmt.Errorf("replace op '%s' is missing a value", path)
+		}
+	case "remove":
+		if hasValue {
+			return Op{}, fmt.Errorf("remove op '%s' must not have a value", path)
+		}
+	case "merge":
+		if !hasValue {
+			return Op{}, fmt.Errorf("merge op '%s' is missing a value", path)
+		}
+
+		if listMode, ok := entry["list_mode"]; ok {
+			listModeString, ok := listMode.(string)
+			op.ListMode = ListMode(listModeString)
+			if !ok || !op.ListMode.valid() {
+				return Op{}, fmt.Errorf("merge op '%s' has an unsupported list_mode '%v'", path, listMode)
+			}
+		}
+
+		if createParents, ok := entry["create_parents"]; ok {
+			op.CreateParents, ok = createParents.(bool)
+			if !ok {
+				return Op{}, fmt.Errorf("merge op '%s' has a non-boolean create_parents", path)
+			}
+		}
+	case "test":
+		absent, ok := entry["absent"]
+		if ok {
+			op.Absent, ok = absent.(bool)
+			if !ok {
+				return Op{}, fmt.Errorf("test op '%s' has a non-boolean absent", path)
+			}
+		}
+
+		if op.Absent == hasValue {
+			return Op{}, fmt.Errorf("test op '%s' must have exactly one of value or absent", path)
+		}
+	default:
+		return Op{}, fmt.Errorf("op type %s not supported by destiny", opType)
+	}
+
+	if _, ok := entry["absent"]; ok && opType != "test" {
+		return Op{}, fmt.Errorf("%s op '%s' must not have absent", opType, path)
+	}
+
+	for _, key := range []string{"list_mode", "create_parents"} {
+		if _, ok := entry[key]; ok && opType != "merge" {
+			return Op{}, fmt.Errorf("%s op '%s' must not have %s", opType, path, key)
+		}
+	}
+
+	if condition, ok := entry["if"]; ok {
+		op.If, err = parseCondition(condition)
+		if err != nil {
+			return Op{}, fmt.Errorf("%s op '%s' has an invalid condition: %s", opType, path, err)
+		}
+	}
+
+	return op, nil
+}
+
+func parseCondition(condition interface{}) (*Condition, error) {
+	entry, ok := condition.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a map")
+	}
+
+	for key := range entry {
+		keyString, ok := key.(string)
+		if !ok || !conditionKeys[keyString] {
+			return nil, fmt.Errorf("unknown key '%v'", key)
+		}
+	}
+
+	path, ok := entry["path"].(string)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("missing or invalid path")
+	}
+
+	_, err := patch.NewPointerFromString(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path '%s': %s", path, err)
+	}
+
+	parsed := &Condition{
+		Path:  path,
+		Value: entry["value"],
+	}
+
+	if absent, ok := entry["absent"]; ok {
+		parsed.Absent, ok = absent.(bool)
+		if !ok {
+			return nil, fmt.Errorf("non-boolean absent")
+		}
+	}
+
+	if _, hasValue := entry["value"]; hasValue && parsed.Absent {
+		return nil, fmt.Errorf("must not have both value and absent")
+	}
+
+	return parsed, nil
+}
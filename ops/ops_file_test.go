@@ -0,0 +1,308 @@
+package ops_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OpsFile", func() {
+	var tempFile = func(contents string) string {
+		file, err := ioutil.TempFile("", "ops-file")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = file.WriteString(contents)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(file.Close()).To(Succeed())
+
+		return file.Name()
+	}
+
+	Describe("LoadOpsFile", func() {
+		var opsFilePath string
+
+		BeforeEach(func() {
+			opsFilePath = tempFile(`
+- type: replace
+  path: /instance_groups/name=consul/instances
+  value: 3
+- type: remove
+  path: /instance_groups/name=testconsumer
+- type: test
+  path: /instance_groups/name=consul/vm_extensions
+  absent: true`)
+		})
+
+		AfterEach(func() {
+			Expect(os.Remove(opsFilePath)).To(Succeed())
+		})
+
+		It("returns the ops in the file", func() {
+			loadedOps, err := ops.LoadOpsFile(opsFilePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(loadedOps).To(Equal([]ops.Op{
+				{
+					Type:  "replace",
+					Path:  "/instance_groups/name=consul/instances",
+					Value: 3,
+				},
+				{
+					Type: "remove",
+					Path: "/instance_groups/name=testconsumer",
+				},
+				{
+					Type:   "test",
+					Path:   "/instance_groups/name=consul/vm_extensions",
+					Absent: true,
+				},
+			}))
+		})
+
+		Context("failure cases", func() {
+			Context("when the file does not exist", func() {
+				It("returns an error", func() {
+					_, err := ops.LoadOpsFile("/some/missing/ops-file.yml")
+					Expect(err).To(MatchError("open /some/missing/ops-file.yml: no such file or directory"))
+				})
+			})
+		})
+	})
+
+	Describe("ReadOps", func() {
+		It("returns the ops from a JSON document", func() {
+			loadedOps, err := ops.ReadOps(strings.NewReader(`[{"type": "replace", "path": "/name", "value": "some-name"}]`), "ops.json")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(loadedOps).To(Equal([]ops.Op{
+				{
+					Type:  "replace",
+					Path:  "/name",
+					Value: "some-name",
+				},
+			}))
+		})
+
+		Context("failure cases", func() {
+			Context("when the ops file is not a list", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("name: some-name"), "some-ops.yml")
+					Expect(err).To(MatchError(ContainSubstring("some-ops.yml: yaml: unmarshal errors")))
+				})
+			})
+
+			Context("when an entry has an unknown key", func() {
+				It("returns an error naming the file and entry", func() {
+					_, err := ops.ReadOps(strings.NewReader(`
+- type: remove
+  path: /name
+- type: replace
+  path: /name
+  valeu: some-name`), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 1: unknown key 'valeu'"))
+				})
+			})
+
+			Context("when an entry has no type", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- path: /name"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: missing or invalid type"))
+				})
+			})
+
+			Context("when an entry has an unsupported type", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: other, path: /name}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: op type other not supported by destiny"))
+				})
+			})
+
+			Context("when an entry has no path", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- type: remove"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: missing or invalid path"))
+				})
+			})
+
+			Context("when an entry has a bad path", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: remove, path: name}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: invalid path 'name': Expected to start with '/'"))
+				})
+			})
+
+			Context("when a replace op has no value", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: replace, path: /name}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: replace op '/name' is missing a value"))
+				})
+			})
+
+			Context("when a remove op has a value", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: remove, path: /name, value: some-name}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: remove op '/name' must not have a value"))
+				})
+			})
+
+			Context("when a test op has both a value and absent", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: test, path: /name, value: some-name, absent: true}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: test op '/name' must have exactly one of value or absent"))
+				})
+			})
+		})
+	})
+
+	Describe("conditions", func() {
+		It("parses the condition of an op", func() {
+			loadedOps, err := ops.ParseOps(`
+- type: replace
+  path: /instance_groups/name=testconsumer/vm_extensions?
+  value: [50GB_ephemeral_disk]
+  if:
+    path: /instance_groups/name=testconsumer
+- type: remove
+  path: /stemcells/alias=windows
+  if: {path: /instance_groups/name=testconsumer/stemcell, value: default}`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(loadedOps[0].If).To(Equal(&ops.Condition{Path: "/instance_groups/name=testconsumer"}))
+			Expect(loadedOps[1].If).To(Equal(&ops.Condition{Path: "/instance_groups/name=testconsumer/stemcell", Value: "default"}))
+		})
+
+		Context("failure cases", func() {
+			Context("when the condition is not a map", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: remove, path: /name, if: /name}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: remove op '/name' has an invalid condition: expected a map"))
+				})
+			})
+
+			Context("when the condition has an unknown key", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: remove, path: /name, if: {path: /name, equals: x}}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: remove op '/name' has an invalid condition: unknown key 'equals'"))
+				})
+			})
+
+			Context("when the condition has a bad path", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: remove, path: /name, if: {path: name}}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: remove op '/name' has an invalid condition: invalid path 'name': Expected to start with '/'"))
+				})
+			})
+
+			Context("when the condition has both a value and absent", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: remove, path: /name, if: {path: /name, value: x, absent: true}}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: remove op '/name' has an invalid condition: must not have both value and absent"))
+				})
+			})
+		})
+	})
+
+	Describe("merge ops", func() {
+		It("parses the merge options", func() {
+			loadedOps, err := ops.ParseOps("- {type: merge, path: /properties, value: {a: 1}, list_mode: name, create_parents: true}")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(loadedOps).To(Equal([]ops.Op{
+				{
+					Type:          "merge",
+					Path:          "/properties",
+					Value:         map[interface{}]interface{}{"a": 1},
+					ListMode:      ops.ListModeMergeByName,
+					CreateParents: true,
+				},
+			}))
+		})
+
+		Context("failure cases", func() {
+			Context("when a merge op has no value", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: merge, path: /properties}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: merge op '/properties' is missing a value"))
+				})
+			})
+
+			Context("when the list mode is not supported", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: merge, path: /properties, value: {}, list_mode: prepend}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: merge op '/properties' has an unsupported list_mode 'prepend'"))
+				})
+			})
+
+			Context("when another op type has merge options", func() {
+				It("returns an error", func() {
+					_, err := ops.ReadOps(strings.NewReader("- {type: replace, path: /properties, value: {}, create_parents: true}"), "some-ops.yml")
+					Expect(err).To(MatchError("some-ops.yml: entry 0: replace op '/properties' must not have create_parents"))
+				})
+			})
+		})
+	})
+
+	Describe("ParseOps", func() {
+		It("returns the ops from a string", func() {
+			loadedOps, err := ops.ParseOps("- {type: replace, path: /name, value: some-name}")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(loadedOps).To(Equal([]ops.Op{
+				{
+					Type:  "replace",
+					Path:  "/name",
+					Value: "some-name",
+				},
+			}))
+		})
+	})
+
+	Describe("LoadVarsFile", func() {
+		var varsFilePath string
+
+		BeforeEach(func() {
+			varsFilePath = tempFile(`
+deployment_name: some-name
+azs: [z1, z2]`)
+		})
+
+		AfterEach(func() {
+			Expect(os.Remove(varsFilePath)).To(Succeed())
+		})
+
+		It("returns the vars in the file", func() {
+			vars, err := ops.LoadVarsFile(varsFilePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(vars).To(Equal(map[string]interface{}{
+				"deployment_name": "some-name",
+				"azs":             []interface{}{"z1", "z2"},
+			}))
+		})
+
+		Context("failure cases", func() {
+			Context("when the file does not exist", func() {
+				It("returns an error", func() {
+					_, err := ops.LoadVarsFile("/some/missing/vars-file.yml")
+					Expect(err).To(MatchError("open /some/missing/vars-file.yml: no such file or directory"))
+				})
+			})
+
+			Context("when the vars file is not a map", func() {
+				It("returns an error", func() {
+					path := tempFile("- some-var")
+					defer os.Remove(path)
+
+					_, err := ops.LoadVarsFile(path)
+					Expect(err).To(MatchError(ContainSubstring(path + ": yaml: unmarshal errors")))
+				})
+			})
+		})
+	})
+})
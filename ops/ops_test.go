@@ -1,7 +1,8 @@
 package ops_test
 
 import (
-	"errors"
+	"io/ioutil"
+	"path/filepath"
 
 	"github.com/pivotal-cf-experimental/destiny/ops"
 	"github.com/pivotal-cf-experimental/gomegamatchers"
@@ -39,6 +40,55 @@ favorite_color: blue`
 ---
 favorite_color: blue`))
 		})
+
+		It("returns the manifest unchanged when a test op passes", func() {
+			manifest := `
+---
+name: some-name
+instance_groups:
+- name: some-instance-group
+  azs: [z1, z2]`
+			modifiedManifest, err := ops.ApplyOp(manifest, ops.Op{
+				Type:  "test",
+				Path:  "/instance_groups/name=some-instance-group/azs",
+				Value: []string{"z1", "z2"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(gomegamatchers.MatchYAML(manifest))
+		})
+
+		It("returns the manifest unchanged when an absent test op passes", func() {
+			manifest := `
+---
+name: some-name
+instance_groups:
+- name: some-instance-group`
+			modifiedManifest, err := ops.ApplyOp(manifest, ops.Op{
+				Type:   "test",
+				Path:   "/instance_groups/name=some-instance-group/azs",
+				Absent: true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(gomegamatchers.MatchYAML(manifest))
+		})
+
+		It("treats a name selector that matches no item as absent", func() {
+			manifest := `
+---
+name: some-name
+instance_groups:
+- name: some-instance-group`
+			modifiedManifest, err := ops.ApplyOp(manifest, ops.Op{
+				Type:   "test",
+				Path:   "/instance_groups/name=some-other-instance-group",
+				Absent: true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(gomegamatchers.MatchYAML(manifest))
+		})
 	})
 
 	Describe("ApplyOps", func() {
@@ -67,39 +117,108 @@ name: some-changed-name
 favorite_color: red`))
 		})
 
+		It("lets later ops reach into values set by earlier ops", func() {
+			modifiedManifest, err := ops.ApplyOps("name: some-name", []ops.Op{
+				{
+					Type:  "replace",
+					Path:  "/instance_groups?",
+					Value: []map[string]interface{}{{"name": "consul", "azs": []string{"z1"}}},
+				},
+				{
+					Type:  "replace",
+					Path:  "/instance_groups/name=consul/azs/0",
+					Value: "z2",
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(gomegamatchers.MatchYAML(`
+name: some-name
+instance_groups:
+- name: consul
+  azs: [z2]`))
+		})
+
 		Context("failure cases", func() {
-			Context("when apply ops fails to marshal", func() {
-				BeforeEach(func() {
-					ops.SetMarshal(func(interface{}) ([]byte, error) {
-						return []byte{}, errors.New("failed to marshal")
+			Context("when apply ops fails to unmarshal", func() {
+				It("returns an error", func() {
+					_, err := ops.ApplyOps("%%%", []ops.Op{})
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+				})
+			})
+
+			Context("when the op type is not supported", func() {
+				It("returns an error", func() {
+					_, err := ops.ApplyOps("some-manifest", []ops.Op{
+						{
+							Type: "other",
+						},
 					})
+					Expect(err).To(MatchError("op 0 (other '') failed: op type other not supported by destiny"))
 				})
+			})
 
-				AfterEach(func() {
-					ops.ResetMarshal()
+			Context("when a test op does not match the value", func() {
+				It("returns an error", func() {
+					_, err := ops.ApplyOps("name: some-name", []ops.Op{
+						{
+							Type:  "test",
+							Path:  "/name",
+							Value: "some-other-name",
+						},
+					})
+					Expect(err).To(MatchError("op 0 (test '/name') failed: test op failed: expected '/name' to be 'some-other-name' but found 'some-name'"))
 				})
+			})
 
+			Context("when a test op path does not exist", func() {
 				It("returns an error", func() {
-					_, err := ops.ApplyOps("some-manifest", []ops.Op{})
-					Expect(err).To(MatchError("failed to marshal"))
+					_, err := ops.ApplyOps("name: some-name", []ops.Op{
+						{
+							Type:  "test",
+							Path:  "/color",
+							Value: "blue",
+						},
+					})
+					Expect(err).To(MatchError("op 0 (test '/color') failed: test op failed: expected '/color' to be 'blue': Expected to find a map key 'color' for path '/color' (found map keys: 'name') (parent at line 1, column 1)"))
 				})
 			})
 
-			Context("when apply ops fails to unmarshal", func() {
+			Context("when an absent test op finds a value", func() {
 				It("returns an error", func() {
-					_, err := ops.ApplyOps("%%%", []ops.Op{})
-					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+					_, err := ops.ApplyOps("name: some-name", []ops.Op{
+						{
+							Type:   "test",
+							Path:   "/name",
+							Absent: true,
+						},
+					})
+					Expect(err).To(MatchError("op 0 (test '/name') failed: test op failed: expected '/name' to be absent but found 'some-name'"))
 				})
 			})
 
-			Context("when the op type is not supported", func() {
+			Context("when an absent test op cannot reach the parent of its path", func() {
+				It("returns an error", func() {
+					_, err := ops.ApplyOps("name: some-name", []ops.Op{
+						{
+							Type:   "test",
+							Path:   "/instance_groups/name=some-instance-group",
+							Absent: true,
+						},
+					})
+					Expect(err).To(MatchError("op 0 (test '/instance_groups/name=some-instance-group') failed: test op failed: expected '/instance_groups/name=some-instance-group' to be absent: Expected to find a map key 'instance_groups' for path '/instance_groups' (found map keys: 'name') (parent at line 1, column 1)"))
+				})
+			})
+
+			Context("when the test op path is bad", func() {
 				It("returns an error", func() {
 					_, err := ops.ApplyOps("some-manifest", []ops.Op{
 						{
-							Type: "other",
+							Type: "test",
+							Path: "%%%",
 						},
 					})
-					Expect(err).To(MatchError("op type other not supported by destiny"))
+					Expect(err).To(MatchError("op 0 (test '%%%') failed: Expected to start with '/'"))
 				})
 			})
 
@@ -111,7 +230,7 @@ favorite_color: red`))
 							Path: "%%%",
 						},
 					})
-					Expect(err).To(MatchError("Expected to start with '/'"))
+					Expect(err).To(MatchError("op 0 (replace '%%%') failed: Expected to start with '/'"))
 				})
 			})
 
@@ -123,7 +242,192 @@ favorite_color: red`))
 							Path: "%%%",
 						},
 					})
-					Expect(err).To(MatchError("Expected to start with '/'"))
+					Expect(err).To(MatchError("op 0 (remove '%%%') failed: Expected to start with '/'"))
+				})
+			})
+		})
+	})
+
+	Describe("ApplyOpsWithOptions", func() {
+		Context("when preserving the layout", func() {
+			It("keeps key order, comments and block scalars", func() {
+				manifest := `name: some-name
+# the releases
+releases:
+- name: consul
+  version: latest # pinned later
+
+instance_groups:
+- name: consul
+  instances: 3
+  properties:
+    certificate: |+
+      -----BEGIN CERTIFICATE-----
+      some-certificate
+      -----END CERTIFICATE-----
+    key: |-
+      some-key
+    ports: [8500, 8501]
+  azs:
+  - z1`
+
+				modifiedManifest, err := ops.ApplyOpsWithOptions(manifest, []ops.Op{
+					{
+						Type:  "replace",
+						Path:  "/releases/name=consul/version",
+						Value: "1.2.3",
+					},
+					{
+						Type:  "replace",
+						Path:  "/instance_groups/name=consul/instances",
+						Value: 1,
+					},
+				}, ops.ApplyOptions{PreserveLayout: true})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(modifiedManifest).To(Equal(`name: some-name
+# the releases
+releases:
+- name: consul
+  version: 1.2.3 # pinned later
+
+instance_groups:
+- name: consul
+  instances: 1
+  properties:
+    certificate: |+
+      -----BEGIN CERTIFICATE-----
+      some-certificate
+      -----END CERTIFICATE-----
+    key: |-
+      some-key
+    ports: [8500, 8501]
+  azs:
+  - z1`))
+			})
+
+			It("writes an unchanged manifest back byte for byte", func() {
+				fixtures, err := filepath.Glob("../*/fixtures/*.yml")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fixtures).NotTo(BeEmpty())
+
+				for _, fixture := range fixtures {
+					contents, err := ioutil.ReadFile(fixture)
+					Expect(err).NotTo(HaveOccurred())
+
+					manifest, err := ops.ApplyOpsWithOptions(string(contents), []ops.Op{}, ops.ApplyOptions{PreserveLayout: true})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(manifest).To(Equal(string(contents)), fixture)
+				}
+			})
+
+			It("keeps the style of replaced quoted strings", func() {
+				manifest := `name: "some-name"
+version: '1'`
+
+				modifiedManifest, err := ops.ApplyOpsWithOptions(manifest, []ops.Op{
+					{
+						Type:  "replace",
+						Path:  "/name",
+						Value: "some-other-name",
+					},
+				}, ops.ApplyOptions{PreserveLayout: true})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(modifiedManifest).To(Equal(`name: "some-other-name"
+version: '1'`))
+			})
+
+			It("keeps the final line break of an untouched block scalar at the end of the manifest", func() {
+				manifest := `name: some-name
+certificate: |
+  -----BEGIN CERTIFICATE-----
+  some-certificate
+  -----END CERTIFICATE-----
+`
+
+				modifiedManifest, err := ops.ApplyOpsWithOptions(manifest, []ops.Op{
+					{Type: "replace", Path: "/name", Value: "some-other-name"},
+				}, ops.ApplyOptions{PreserveLayout: true})
+				Expect(err).NotTo(HaveOccurred())
+
+				certificate, err := ops.FindOp(modifiedManifest, "/certificate")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(certificate).To(Equal("-----BEGIN CERTIFICATE-----\nsome-certificate\n-----END CERTIFICATE-----\n"))
+			})
+
+			It("keeps the line comment of a value replaced with a multiline string out of its content", func() {
+				modifiedManifest, err := ops.ApplyOpsWithOptions(`key: some-key # rotated yearly
+name: some-name`, []ops.Op{
+					{Type: "replace", Path: "/key", Value: "some-key\nsome-more-key"},
+				}, ops.ApplyOptions{PreserveLayout: true})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(modifiedManifest).To(ContainSubstring("# rotated yearly"))
+
+				key, err := ops.FindOp(modifiedManifest, "/key")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(key).To(Equal("some-key\nsome-more-key"))
+			})
+
+			It("writes replaced strings with leading line breaks, carriage returns and trailing spaces unchanged", func() {
+				values := []string{"\nsome-value", "\n\nsome-value\n", "some-value\r\nsome-other-value", "some-value  ", "some-value  \nsome-other-value"}
+
+				for _, value := range values {
+					modifiedManifest, err := ops.ApplyOpsWithOptions(`name: some-name
+value: some-value`, []ops.Op{
+						{Type: "replace", Path: "/value", Value: value},
+					}, ops.ApplyOptions{PreserveLayout: true})
+					Expect(err).NotTo(HaveOccurred())
+
+					foundValue, err := ops.FindOp(modifiedManifest, "/value")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(foundValue).To(Equal(value))
+				}
+			})
+
+			It("appends new keys and items and drops removed ones", func() {
+				manifest := `name: some-name
+favorite_color: blue
+jobs:
+- name: some-job
+- name: some-other-job`
+
+				modifiedManifest, err := ops.ApplyOpsWithOptions(manifest, []ops.Op{
+					{
+						Type: "remove",
+						Path: "/favorite_color",
+					},
+					{
+						Type: "remove",
+						Path: "/jobs/name=some-job",
+					},
+					{
+						Type:  "replace",
+						Path:  "/jobs/-",
+						Value: map[string]interface{}{"name": "some-new-job"},
+					},
+					{
+						Type:  "replace",
+						Path:  "/director_uuid?",
+						Value: "some-uuid",
+					},
+				}, ops.ApplyOptions{PreserveLayout: true})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(modifiedManifest).To(Equal(`name: some-name
+jobs:
+- name: some-other-job
+- name: some-new-job
+director_uuid: some-uuid`))
+			})
+		})
+
+		Context("failure cases", func() {
+			Context("when the manifest is not valid yaml", func() {
+				It("returns an error", func() {
+					_, err := ops.ApplyOpsWithOptions("%%%", []ops.Op{}, ops.ApplyOptions{PreserveLayout: true})
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
 				})
 			})
 		})
@@ -0,0 +1,223 @@
+package ops
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/cppforlife/go-patch/patch"
+)
+
+var pathEscaper = strings.NewReplacer("~", "~0", "/", "~1", ":", "~7")
+
+// Path builds an op path one segment at a time, escaping keys and names so
+// that characters such as '/' and '~' cannot change the meaning of the path.
+// Once a segment is marked optional, every key and name after it is optional
+// as well, matching how go-patch parses paths.
+type Path struct {
+	tokens   []patch.Token
+	optional bool
+}
+
+func NewPath() Path {
+	return Path{tokens: []patch.Token{patch.RootToken{}}}
+}
+
+func ParsePath(path string) (Path, error) {
+	pointer, err := patch.NewPointerFromString(path)
+	if err != nil {
+		return Path{}, err
+	}
+
+	parsed := Path{optional: isOptional(pointer)}
+	for _, token := range pointer.Tokens() {
+		if isWildcard(token) {
+			token = wildcardToken{}
+		}
+		parsed.tokens = append(parsed.tokens, token)
+	}
+
+	return parsed, nil
+}
+
+func (p Path) Key(key string) Path {
+	return p.with(patch.KeyToken{Key: key, Optional: p.optional})
+}
+
+// Name selects the array item whose name is name.
+func (p Path) Name(name string) Path {
+	return p.Match("name", name)
+}
+
+// Match selects the array item whose key is value.
+func (p Path) Match(key, value string) Path {
+	return p.with(patch.MatchingIndexToken{Key: key, Value: value, Optional: p.optional})
+}
+
+func (p Path) Index(index int) Path {
+	return p.with(patch.IndexToken{Index: index})
+}
+
+// Append points after the last item of an array.
+func (p Path) Append() Path {
+	return p.with(patch.AfterLastIndexToken{})
+}
+
+// Wildcard matches every item of an array or every key of a map.
+func (p Path) Wildcard() Path {
+	return p.with(wildcardToken{})
+}
+
+// wildcardToken keeps a Wildcard segment apart from a key that happens to be
+// named '*', which cannot be written as a path.
+type wildcardToken struct {
+	patch.KeyToken
+}
+
+// Optional marks the last key or name segment as optional.
+func (p Path) Optional() Path {
+	tokens := p.copyTokens()
+
+	switch typedToken := tokens[len(tokens)-1].(type) {
+	case patch.KeyToken:
+		typedToken.Optional = true
+		tokens[len(tokens)-1] = typedToken
+	case patch.MatchingIndexToken:
+		typedToken.Optional = true
+		tokens[len(tokens)-1] = typedToken
+	default:
+		return Path{tokens: tokens, optional: p.optional}
+	}
+
+	return Path{tokens: tokens, optional: true}
+}
+
+// String renders the path, marking every optional segment with a '?'.
+func (p Path) String() string {
+	var segments []string
+
+	for _, token := range p.root().tokens {
+		switch typedToken := token.(type) {
+		case patch.RootToken:
+			segments = append(segments, "")
+		case patch.KeyToken:
+			segments = append(segments, pathEscaper.Replace(typedToken.Key)+optionalMarker(typedToken.Optional))
+		case patch.MatchingIndexToken:
+			segments = append(segments, fmt.Sprintf("%s=%s%s%s", pathEscaper.Replace(typedToken.Key), pathEscaper.Replace(typedToken.Value),
+				optionalMarker(typedToken.Optional), modifiersString(typedToken.Modifiers)))
+		case patch.IndexToken:
+			segments = append(segments, strconv.Itoa(typedToken.Index)+modifiersString(typedToken.Modifiers))
+		default:
+			segments = append(segments, segmentText(token))
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// Validate returns an error when a segment cannot be written as a path
+// string, for example a key that looks like an index or contains an '='.
+func (p Path) Validate() error {
+	path := p.root()
+
+	parsed, err := ParsePath(path.String())
+	if err != nil {
+		// not tested
+		return err
+	}
+
+	for i, token := range path.tokens {
+		if i >= len(parsed.tokens) || !reflect.DeepEqual(token, parsed.tokens[i]) {
+			return fmt.Errorf("path segment '%s' cannot be represented in path '%s'", segmentText(token), path)
+		}
+	}
+
+	return nil
+}
+
+func (p Path) with(token patch.Token) Path {
+	path := p.root()
+
+	return Path{
+		tokens:   append(path.copyTokens(), token),
+		optional: path.optional,
+	}
+}
+
+func (p Path) root() Path {
+	if len(p.tokens) == 0 {
+		return NewPath()
+	}
+
+	return p
+}
+
+func (p Path) copyTokens() []patch.Token {
+	return append([]patch.Token{}, p.root().tokens...)
+}
+
+// segmentText returns the unescaped text of a single segment.
+func segmentText(token patch.Token) string {
+	switch typedToken := token.(type) {
+	case patch.KeyToken:
+		return typedToken.Key
+	case patch.MatchingIndexToken:
+		return typedToken.Key + "=" + typedToken.Value
+	case patch.IndexToken:
+		return strconv.Itoa(typedToken.Index)
+	case patch.AfterLastIndexToken:
+		return "-"
+	case wildcardToken:
+		return wildcard
+	default:
+		return ""
+	}
+}
+
+func optionalMarker(optional bool) string {
+	if optional {
+		return "?"
+	}
+
+	return ""
+}
+
+func modifiersString(modifiers []patch.Modifier) string {
+	var str string
+
+	for _, modifier := range modifiers {
+		switch modifier.(type) {
+		case patch.PrevModifier:
+			str += ":prev"
+		case patch.NextModifier:
+			str += ":next"
+		case patch.BeforeModifier:
+			str += ":before"
+		case patch.AfterModifier:
+			str += ":after"
+		}
+	}
+
+	return str
+}
+
+// Replace, Remove, Test and TestAbsent build ops from a Path. When path has a
+// segment that cannot be represented, see Path.Validate, applying the op
+// fails with an OpError whose cause is BadPointer instead of silently
+// pointing somewhere else.
+func Replace(path Path, value interface{}) Op {
+	return Op{Type: "replace", Path: path.String(), Value: value, pathErr: path.Validate()}
+}
+
+func Remove(path Path) Op {
+	return Op{Type: "remove", Path: path.String(), pathErr: path.Validate()}
+}
+
+func Test(path Path, value interface{}) Op {
+	return Op{Type: "test", Path: path.String(), Value: value, pathErr: path.Validate()}
+}
+
+func TestAbsent(path Path) Op {
+	return Op{Type: "test", Path: path.String(), Absent: true, pathErr: path.Validate()}
+}
@@ -0,0 +1,134 @@
+package ops_test
+
+import (
+	"errors"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Path", func() {
+	It("builds a path from segments", func() {
+		path := ops.NewPath().Key("instance_groups").Name("testconsumer").Key("jobs").Name("consul_agent").Key("name")
+
+		Expect(path.String()).To(Equal("/instance_groups/name=testconsumer/jobs/name=consul_agent/name"))
+		Expect(path.Validate()).To(Succeed())
+	})
+
+	It("builds index, append, match and wildcard segments", func() {
+		Expect(ops.NewPath().Key("stemcells").Append().String()).To(Equal("/stemcells/-"))
+		Expect(ops.NewPath().Key("instance_groups").Index(0).Key("azs").String()).To(Equal("/instance_groups/0/azs"))
+		Expect(ops.NewPath().Key("stemcells").Match("alias", "windows").String()).To(Equal("/stemcells/alias=windows"))
+		Expect(ops.NewPath().Key("instance_groups").Wildcard().Key("azs").String()).To(Equal("/instance_groups/*/azs"))
+	})
+
+	It("escapes keys and names", func() {
+		path := ops.NewPath().Key("properties").Key("a/b~c").Key("jobs").Name("some/name=with~chars")
+
+		Expect(path.String()).To(Equal("/properties/a~1b~0c/jobs/name=some~1name=with~0chars"))
+		Expect(path.Validate()).To(Succeed())
+
+		value, err := ops.FindOp(`properties:
+  a/b~c:
+    jobs:
+    - name: some/name=with~chars
+      value: found`, path.Key("value").String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(Equal("found"))
+	})
+
+	It("marks segments as optional", func() {
+		path := ops.NewPath().Key("instance_groups").Name("testconsumer").Key("vm_extensions").Optional()
+		Expect(path.String()).To(Equal("/instance_groups/name=testconsumer/vm_extensions?"))
+
+		path = ops.NewPath().Key("properties").Optional().Key("consul").Key("agent")
+		Expect(path.String()).To(Equal("/properties?/consul?/agent?"))
+		Expect(path.Validate()).To(Succeed())
+	})
+
+	It("round trips to and from strings", func() {
+		for _, pathString := range []string{
+			"",
+			"/name",
+			"/instance_groups/name=consul/jobs/0/properties?/consul?",
+			"/stemcells/-",
+			"/properties/a~1b~0c",
+			"/instance_groups/*/azs",
+		} {
+			path, err := ops.ParsePath(pathString)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path.String()).To(Equal(pathString))
+			Expect(path.Validate()).To(Succeed())
+		}
+	})
+
+	It("treats the zero value as the root", func() {
+		var path ops.Path
+		Expect(path.String()).To(Equal(""))
+		Expect(path.Key("name").String()).To(Equal("/name"))
+	})
+
+	Describe("op constructors", func() {
+		It("builds ops that can be applied", func() {
+			instanceGroup := ops.NewPath().Key("instance_groups").Name("consul")
+
+			modifiedManifest, err := ops.ApplyOps(`instance_groups:
+- name: consul
+  azs: [z1]
+  vm_type: default`, []ops.Op{
+				ops.Test(instanceGroup.Key("vm_type"), "default"),
+				ops.TestAbsent(instanceGroup.Key("lifecycle")),
+				ops.Replace(instanceGroup.Key("azs").Append(), "z2"),
+				ops.Remove(instanceGroup.Key("vm_type")),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(Equal(`instance_groups:
+- azs:
+  - z1
+  - z2
+  name: consul`))
+		})
+	})
+
+	Context("failure cases", func() {
+		Context("when a key cannot be represented", func() {
+			It("returns an error", func() {
+				Expect(ops.NewPath().Key("ports").Key("8080").Validate()).To(MatchError("path segment '8080' cannot be represented in path '/ports/8080'"))
+				Expect(ops.NewPath().Key("a=b").Validate()).To(MatchError("path segment 'a=b' cannot be represented in path '/a=b'"))
+				Expect(ops.NewPath().Key("*").Validate()).To(MatchError("path segment '*' cannot be represented in path '/*'"))
+			})
+
+			It("fails to apply an op built from the path", func() {
+				for _, op := range []ops.Op{
+					ops.Replace(ops.NewPath().Key("ports").Key("8080"), 1),
+					ops.Remove(ops.NewPath().Key("a=b")),
+					ops.Test(ops.NewPath().Key("*"), 1),
+					ops.TestAbsent(ops.NewPath().Key("8080")),
+					ops.Merge(ops.NewPath().Key("a=b"), map[string]interface{}{}),
+				} {
+					_, err := ops.ApplyOps("name: some-name", []ops.Op{op})
+
+					var opErr ops.OpError
+					Expect(errors.As(err, &opErr)).To(BeTrue())
+					Expect(opErr.Cause).To(Equal(ops.BadPointer))
+				}
+
+				_, err := ops.ApplyOps("name: some-name", []ops.Op{ops.Remove(ops.NewPath().Key("name")).IfExists(ops.NewPath().Key("*"))})
+				Expect(err).To(MatchError("op 0 (remove '/name') failed: invalid condition path '/*': path segment '*' cannot be represented in path '/*'"))
+
+				_, err = ops.ApplyOps("name: some-name", []ops.Op{ops.Replace(ops.NewPath().Key("ports").Key("8080"), 1)})
+				Expect(err).To(MatchError("op 0 (replace '/ports/8080') failed: path segment '8080' cannot be represented in path '/ports/8080'"))
+			})
+		})
+
+		Context("when the path string is bad", func() {
+			It("returns an error", func() {
+				_, err := ops.ParsePath("name")
+				Expect(err).To(MatchError("Expected to start with '/'"))
+			})
+		})
+	})
+})
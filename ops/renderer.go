@@ -0,0 +1,182 @@
+package ops
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+type OutputFormat string
+
+const (
+	YAMLOutput        OutputFormat = "yaml"
+	JSONOutput        OutputFormat = "json"
+	CompactJSONOutput OutputFormat = "compact-json"
+)
+
+// Renderer applies ops and renders manifests using its own marshaller, output
+// format and options. None of its methods modify the Renderer, so a single
+// value can be shared between goroutines. The zero value renders YAML with
+// yaml.Marshal. JSONOutput renders canonical JSON, with sorted keys and two
+// space indentation, and CompactJSONOutput the same without any whitespace.
+// With Conventional set, YAML is rendered the way Document.Format writes it.
+type Renderer struct {
+	Marshal      func(interface{}) ([]byte, error)
+	Format       OutputFormat
+	Options      ApplyOptions
+	Conventional bool
+}
+
+func (r Renderer) ApplyOp(manifest string, op Op) (string, error) {
+	return r.ApplyOps(manifest, []Op{op})
+}
+
+func (r Renderer) ApplyOps(manifest string, ops []Op) (string, error) {
+	manifestYAML, _, err := r.ApplyOpsWithReport(manifest, ops)
+	return manifestYAML, err
+}
+
+func (r Renderer) ApplyOpsWithReport(manifest string, ops []Op) (string, ApplyReport, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return "", ApplyReport{}, err
+	}
+
+	document, report, err := document.ApplyOpsWithReport(ops)
+	if err != nil {
+		return "", ApplyReport{}, err
+	}
+
+	manifestYAML, err := r.RenderDocument(document)
+	if err != nil {
+		return "", ApplyReport{}, err
+	}
+
+	return manifestYAML, report, nil
+}
+
+func (r Renderer) ApplyOpsToDocument(manifest string, selector DocumentSelector, ops []Op) (string, error) {
+	stream, err := ParseStream(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	stream, err = stream.ApplyOps(selector, ops)
+	if err != nil {
+		return "", err
+	}
+
+	return r.RenderStream(stream)
+}
+
+// RenderDocument serializes a document. Scalars that no op changed are
+// written the way the source wrote them. When the options ask for the layout
+// to be preserved, the document is rendered on top of the manifest it was
+// parsed from.
+func (r Renderer) RenderDocument(document Document) (string, error) {
+	yamlOutput := r.Marshal == nil && r.format() == YAMLOutput
+
+	switch {
+	case yamlOutput && r.Options.PreserveLayout && document.tree != nil:
+		return applyLayout(document.source, document.original, document.doc)
+	case yamlOutput && r.Conventional:
+		return document.Format()
+	}
+
+	output, err := r.render(document.doc)
+	if err != nil {
+		return "", err
+	}
+
+	if !yamlOutput {
+		return output, nil
+	}
+
+	return document.tree.restoreScalars(output, document.original, document.doc)
+}
+
+// WriteDocument writes a rendered document to w, ending it with a newline
+// unless it already ends with one. JSON is encoded straight into w.
+func (r Renderer) WriteDocument(w io.Writer, document Document) error {
+	if r.jsonOutput() {
+		return encodeJSON(w, document.doc, r.format() == JSONOutput)
+	}
+
+	output, err := r.RenderDocument(document)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+
+	_, err = io.WriteString(w, output)
+	return err
+}
+
+// RenderStream serializes every document of a stream. YAML documents are
+// separated with "---" lines the way they were separated in the stream that
+// was parsed, JSON documents are written one after the other.
+func (r Renderer) RenderStream(stream Stream) (string, error) {
+	if r.jsonOutput() {
+		outputs := []string{}
+		for _, document := range stream.documents {
+			output, err := r.RenderDocument(document)
+			if err != nil {
+				return "", err
+			}
+
+			outputs = append(outputs, output)
+		}
+
+		return strings.Join(outputs, "\n"), nil
+	}
+
+	return stream.join(r.RenderDocument, r.Marshal == nil && !r.Conventional)
+}
+
+func (r Renderer) MarshalManifest(manifest Manifest) (string, error) {
+	return r.render(manifest)
+}
+
+func (r Renderer) render(value interface{}) (string, error) {
+	marshal := r.Marshal
+	if marshal == nil {
+		switch r.format() {
+		case YAMLOutput:
+			marshal = yaml.Marshal
+		case JSONOutput:
+			marshal = func(value interface{}) ([]byte, error) {
+				return marshalJSON(value, true)
+			}
+		case CompactJSONOutput:
+			marshal = func(value interface{}) ([]byte, error) {
+				return marshalJSON(value, false)
+			}
+		default:
+			return "", fmt.Errorf("output format %s not supported by destiny", r.Format)
+		}
+	}
+
+	output, err := marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Trim(string(output), "\n"), nil
+}
+
+func (r Renderer) jsonOutput() bool {
+	return r.Marshal == nil && (r.format() == JSONOutput || r.format() == CompactJSONOutput)
+}
+
+func (r Renderer) format() OutputFormat {
+	if r.Format == "" {
+		return YAMLOutput
+	}
+
+	return r.Format
+}
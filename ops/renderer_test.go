@@ -0,0 +1,242 @@
+package ops_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Renderer", func() {
+	Describe("ApplyOps", func() {
+		It("renders the manifest with its own marshaller", func() {
+			renderer := ops.Renderer{
+				Marshal: func(value interface{}) ([]byte, error) {
+					return []byte(fmt.Sprintf("\n%v\n", value)), nil
+				},
+			}
+
+			modifiedManifest, err := renderer.ApplyOp("name: some-name", ops.Op{
+				Type:  "replace",
+				Path:  "/name",
+				Value: "some-changed-name",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(Equal("map[name:some-changed-name]"))
+		})
+
+		It("preserves the layout when the options ask for it", func() {
+			renderer := ops.Renderer{
+				Options: ops.ApplyOptions{PreserveLayout: true},
+			}
+
+			modifiedManifest, err := renderer.ApplyOps("name: some-name # the name\nfavorite_color: blue", []ops.Op{
+				{
+					Type:  "replace",
+					Path:  "/name",
+					Value: "some-changed-name",
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(Equal("name: some-changed-name # the name\nfavorite_color: blue"))
+		})
+
+		It("renders canonical JSON", func() {
+			modifiedManifest, err := ops.Renderer{Format: ops.JSONOutput}.ApplyOp("name: some-name\nreleases:\n- {name: consul, version: 1.10}", ops.Op{
+				Type:  "replace",
+				Path:  "/instance_groups?/-",
+				Value: map[string]interface{}{"name": "consul", "instances": 3},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(Equal(`{
+  "instance_groups": [
+    {
+      "instances": 3,
+      "name": "consul"
+    }
+  ],
+  "name": "some-name",
+  "releases": [
+    {
+      "name": "consul",
+      "version": 1.1
+    }
+  ]
+}`))
+		})
+
+		It("renders compact JSON", func() {
+			modifiedManifest, err := ops.Renderer{Format: ops.CompactJSONOutput}.ApplyOp("name: some-name\nproperties:\n  url: <https://example.com?a=b&c=d>", ops.Op{
+				Type:  "replace",
+				Path:  "/name",
+				Value: "some-changed-name",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(Equal(`{"name":"some-changed-name","properties":{"url":"<https://example.com?a=b&c=d>"}}`))
+		})
+
+		It("renders YAML in the conventional BOSH order when asked to", func() {
+			modifiedManifest, err := ops.Renderer{Conventional: true}.ApplyOp("instance_groups: []\nreleases: []", ops.Op{
+				Type:  "replace",
+				Path:  "/name?",
+				Value: "some-name",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(Equal("name: some-name\nreleases: []\ninstance_groups: []"))
+		})
+
+		It("can be shared between goroutines alongside other renderers", func() {
+			failing := ops.Renderer{
+				Marshal: func(interface{}) ([]byte, error) {
+					return nil, errors.New("failed to marshal")
+				},
+			}
+
+			var wg sync.WaitGroup
+			errs := make([]error, 20)
+			manifests := make([]string, 20)
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					defer GinkgoRecover()
+
+					if i%2 == 0 {
+						_, errs[i] = failing.ApplyOps("name: some-name", []ops.Op{})
+						return
+					}
+
+					manifests[i], errs[i] = ops.ApplyOp("name: some-name", ops.Op{
+						Type:  "replace",
+						Path:  "/name",
+						Value: fmt.Sprintf("some-name-%d", i),
+					})
+				}(i)
+			}
+			wg.Wait()
+
+			for i := 0; i < 20; i++ {
+				if i%2 == 0 {
+					Expect(errs[i]).To(MatchError("failed to marshal"))
+					continue
+				}
+
+				Expect(errs[i]).NotTo(HaveOccurred())
+				Expect(manifests[i]).To(Equal(fmt.Sprintf("name: some-name-%d", i)))
+			}
+		})
+
+		Context("failure cases", func() {
+			Context("when the marshaller fails", func() {
+				It("returns an error", func() {
+					renderer := ops.Renderer{
+						Marshal: func(interface{}) ([]byte, error) {
+							return []byte{}, errors.New("failed to marshal")
+						},
+					}
+
+					_, err := renderer.ApplyOps("some-manifest", []ops.Op{})
+					Expect(err).To(MatchError("failed to marshal"))
+				})
+			})
+
+			Context("when the output format is not supported", func() {
+				It("returns an error", func() {
+					_, err := ops.Renderer{Format: "toml"}.ApplyOps("some-manifest", []ops.Op{})
+					Expect(err).To(MatchError("output format toml not supported by destiny"))
+				})
+			})
+		})
+	})
+
+	Describe("Interpolate", func() {
+		Context("failure cases", func() {
+			Context("when the marshaller fails", func() {
+				It("returns an error", func() {
+					renderer := ops.Renderer{
+						Marshal: func(interface{}) ([]byte, error) {
+							return []byte{}, errors.New("failed to marshal")
+						},
+					}
+
+					_, err := renderer.Interpolate("name: ((name))", ops.InterpolateOptions{
+						Vars: map[string]interface{}{"name": "some-name"},
+					})
+					Expect(err).To(MatchError("failed to marshal"))
+				})
+			})
+		})
+	})
+
+	Describe("WriteDocument", func() {
+		var document ops.Document
+
+		BeforeEach(func() {
+			var err error
+			document, err = ops.ParseDocument("name: some-name\nreleases:\n- name: consul\n  version: 1.10")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("writes YAML followed by a newline", func() {
+			buffer := &bytes.Buffer{}
+			err := ops.Renderer{}.WriteDocument(buffer, document)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(Equal("name: some-name\nreleases:\n- name: consul\n  version: 1.10\n"))
+		})
+
+		It("encodes JSON into the writer", func() {
+			buffer := &bytes.Buffer{}
+			err := ops.Renderer{Format: ops.CompactJSONOutput}.WriteDocument(buffer, document)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(Equal(`{"name":"some-name","releases":[{"name":"consul","version":1.1}]}` + "\n"))
+		})
+
+		It("returns an error when the output format is not supported", func() {
+			err := ops.Renderer{Format: "toml"}.WriteDocument(&bytes.Buffer{}, document)
+			Expect(err).To(MatchError("output format toml not supported by destiny"))
+		})
+	})
+
+	Describe("RenderStream", func() {
+		It("writes JSON documents one after the other", func() {
+			stream, err := ops.ParseStream("name: consul\n---\nname: etcd")
+			Expect(err).NotTo(HaveOccurred())
+
+			output, err := ops.Renderer{Format: ops.CompactJSONOutput}.RenderStream(stream)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(output).To(Equal(`{"name":"consul"}` + "\n" + `{"name":"etcd"}`))
+		})
+	})
+
+	Describe("MarshalManifest", func() {
+		It("renders a typed manifest", func() {
+			manifestYAML, err := ops.Renderer{}.MarshalManifest(ops.Manifest{Name: "some-name"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifestYAML).To(ContainSubstring("name: some-name"))
+		})
+
+		It("renders a typed manifest as JSON with the manifest's key names", func() {
+			manifestJSON, err := ops.Renderer{Format: ops.CompactJSONOutput}.MarshalManifest(ops.Manifest{
+				Name:     "some-name",
+				Releases: []ops.Release{{Name: "consul", Version: "1.10"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifestJSON).To(HavePrefix(`{"name":"some-name","releases":[{"name":"consul","version":"1.10"}]`))
+		})
+	})
+})
@@ -1,43 +1,223 @@
 package ops
 
-import (
-	"errors"
+func ManifestName(manifest string) (string, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return "", err
+	}
 
-	yaml "gopkg.in/yaml.v2"
-)
+	return document.Name()
+}
 
-type InstanceGroup struct {
-	Name      string
-	Instances int
-	Lifecycle string
+func InstanceGroups(manifest string) ([]InstanceGroup, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return []InstanceGroup{}, err
+	}
+
+	return document.InstanceGroups()
 }
 
-func ManifestName(manifest string) (string, error) {
+func ErrandInstanceGroups(manifest string) ([]InstanceGroup, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return []InstanceGroup{}, err
+	}
+
+	return document.ErrandInstanceGroups()
+}
+
+func Releases(manifest string) ([]Release, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return []Release{}, err
+	}
+
+	return document.Releases()
+}
+
+func Stemcells(manifest string) ([]Stemcell, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return []Stemcell{}, err
+	}
+
+	return document.Stemcells()
+}
+
+func Jobs(manifest, instanceGroupName string) ([]Job, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return []Job{}, err
+	}
+
+	return document.Jobs(instanceGroupName)
+}
+
+func AZs(manifest, instanceGroupName string) ([]string, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return []string{}, err
+	}
+
+	return document.AZs(instanceGroupName)
+}
+
+func Networks(manifest, instanceGroupName string) ([]Network, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return []Network{}, err
+	}
+
+	return document.Networks(instanceGroupName)
+}
+
+func VMType(manifest, instanceGroupName string) (string, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	return document.VMType(instanceGroupName)
+}
+
+func PersistentDiskType(manifest, instanceGroupName string) (string, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	return document.PersistentDiskType(instanceGroupName)
+}
+
+func (d Document) Name() (string, error) {
 	var manifestStruct struct {
 		Name string
 	}
 
-	err := yaml.Unmarshal([]byte(manifest), &manifestStruct)
+	err := d.decode(&manifestStruct, "name")
 	if err != nil {
 		return "", err
 	}
 
 	if manifestStruct.Name == "" {
-		return "", errors.New("could not find name in manifest")
+		return "", ErrNameNotFound
 	}
 
 	return manifestStruct.Name, nil
 }
 
-func InstanceGroups(manifest string) ([]InstanceGroup, error) {
+func (d Document) InstanceGroups() ([]InstanceGroup, error) {
 	var manifestStruct struct {
 		InstanceGroups []InstanceGroup `yaml:"instance_groups"`
 	}
 
-	err := yaml.Unmarshal([]byte(manifest), &manifestStruct)
+	err := d.decode(&manifestStruct, "instance_groups")
 	if err != nil {
 		return []InstanceGroup{}, err
 	}
 
 	return manifestStruct.InstanceGroups, nil
 }
+
+func (d Document) ErrandInstanceGroups() ([]InstanceGroup, error) {
+	instanceGroups, err := d.InstanceGroups()
+	if err != nil {
+		return []InstanceGroup{}, err
+	}
+
+	errands := []InstanceGroup{}
+	for _, instanceGroup := range instanceGroups {
+		if instanceGroup.Lifecycle == "errand" {
+			errands = append(errands, instanceGroup)
+		}
+	}
+
+	return errands, nil
+}
+
+func (d Document) Releases() ([]Release, error) {
+	var manifestStruct struct {
+		Releases []Release
+	}
+
+	err := d.decode(&manifestStruct, "releases")
+	if err != nil {
+		return []Release{}, err
+	}
+
+	return manifestStruct.Releases, nil
+}
+
+func (d Document) Stemcells() ([]Stemcell, error) {
+	var manifestStruct struct {
+		Stemcells []Stemcell
+	}
+
+	err := d.decode(&manifestStruct, "stemcells")
+	if err != nil {
+		return []Stemcell{}, err
+	}
+
+	return manifestStruct.Stemcells, nil
+}
+
+func (d Document) Jobs(instanceGroupName string) ([]Job, error) {
+	instanceGroup, err := d.findInstanceGroup(instanceGroupName)
+	if err != nil {
+		return []Job{}, err
+	}
+
+	return instanceGroup.Jobs, nil
+}
+
+func (d Document) AZs(instanceGroupName string) ([]string, error) {
+	instanceGroup, err := d.findInstanceGroup(instanceGroupName)
+	if err != nil {
+		return []string{}, err
+	}
+
+	return instanceGroup.AZs, nil
+}
+
+func (d Document) Networks(instanceGroupName string) ([]Network, error) {
+	instanceGroup, err := d.findInstanceGroup(instanceGroupName)
+	if err != nil {
+		return []Network{}, err
+	}
+
+	return instanceGroup.Networks, nil
+}
+
+func (d Document) VMType(instanceGroupName string) (string, error) {
+	instanceGroup, err := d.findInstanceGroup(instanceGroupName)
+	if err != nil {
+		return "", err
+	}
+
+	return instanceGroup.VMType, nil
+}
+
+func (d Document) PersistentDiskType(instanceGroupName string) (string, error) {
+	instanceGroup, err := d.findInstanceGroup(instanceGroupName)
+	if err != nil {
+		return "", err
+	}
+
+	return instanceGroup.PersistentDiskType, nil
+}
+
+func (d Document) findInstanceGroup(name string) (InstanceGroup, error) {
+	instanceGroups, err := d.InstanceGroups()
+	if err != nil {
+		return InstanceGroup{}, err
+	}
+
+	for _, instanceGroup := range instanceGroups {
+		if instanceGroup.Name == name {
+			return instanceGroup, nil
+		}
+	}
+
+	return InstanceGroup{}, instanceGroupNotFoundErr{name}
+}
@@ -80,4 +80,178 @@ instance_groups:
 			})
 		})
 	})
+
+	Context("instance group retrievers", func() {
+		var manifest string
+
+		BeforeEach(func() {
+			manifest = `
+releases:
+- name: consul
+  version: latest
+- name: etcd
+  version: 1.2.3
+stemcells:
+- alias: default
+  os: ubuntu-trusty
+  version: latest
+instance_groups:
+- name: consul
+  instances: 1
+  azs: [z1, z2]
+  jobs:
+  - name: consul_agent
+    release: consul
+    provides:
+      consul_common: { as: common_link }
+  vm_type: default
+  persistent_disk_type: 1GB
+  networks:
+  - name: private
+    static_ips: [10.0.0.1]
+- name: testconsumer
+  instances: 1
+  azs: [z1]
+  jobs:
+  - name: consul_agent
+    release: consul
+    consumes:
+      consul_common: { from: common_link }
+      consul_server: nil
+- name: some-errand
+  instances: 1
+  lifecycle: errand`
+		})
+
+		Describe("ErrandInstanceGroups", func() {
+			It("returns only the errand instance groups", func() {
+				errands, err := ops.ErrandInstanceGroups(manifest)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(errands).To(Equal([]ops.InstanceGroup{
+					{
+						Name:      "some-errand",
+						Instances: 1,
+						Lifecycle: "errand",
+					},
+				}))
+			})
+		})
+
+		Describe("Releases", func() {
+			It("returns the releases", func() {
+				releases, err := ops.Releases(manifest)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(releases).To(Equal([]ops.Release{
+					{Name: "consul", Version: "latest"},
+					{Name: "etcd", Version: "1.2.3"},
+				}))
+			})
+		})
+
+		Describe("Stemcells", func() {
+			It("returns the stemcells", func() {
+				stemcells, err := ops.Stemcells(manifest)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(stemcells).To(Equal([]ops.Stemcell{
+					{Alias: "default", OS: "ubuntu-trusty", Version: "latest"},
+				}))
+			})
+		})
+
+		Describe("Jobs", func() {
+			It("returns the jobs of an instance group with their links", func() {
+				jobs, err := ops.Jobs(manifest, "testconsumer")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(jobs).To(Equal([]ops.Job{
+					{
+						Name:    "consul_agent",
+						Release: "consul",
+						Consumes: map[string]ops.ConsumesLink{
+							"consul_common": {From: "common_link"},
+							"consul_server": {Disabled: true},
+						},
+					},
+				}))
+			})
+		})
+
+		Describe("AZs", func() {
+			It("returns the azs of an instance group", func() {
+				azs, err := ops.AZs(manifest, "consul")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(azs).To(Equal([]string{"z1", "z2"}))
+			})
+		})
+
+		Describe("Networks", func() {
+			It("returns the networks of an instance group", func() {
+				networks, err := ops.Networks(manifest, "consul")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(networks).To(Equal([]ops.Network{
+					{Name: "private", StaticIPs: []string{"10.0.0.1"}},
+				}))
+			})
+		})
+
+		Describe("VMType", func() {
+			It("returns the vm type of an instance group", func() {
+				vmType, err := ops.VMType(manifest, "consul")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(vmType).To(Equal("default"))
+			})
+		})
+
+		Describe("PersistentDiskType", func() {
+			It("returns the persistent disk type of an instance group", func() {
+				persistentDiskType, err := ops.PersistentDiskType(manifest, "consul")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(persistentDiskType).To(Equal("1GB"))
+			})
+		})
+
+		Context("failure cases", func() {
+			Context("when the instance group does not exist", func() {
+				It("returns an error", func() {
+					_, err := ops.Jobs(manifest, "etcd")
+					Expect(err).To(MatchError("could not find instance group etcd in manifest"))
+
+					_, err = ops.AZs(manifest, "etcd")
+					Expect(err).To(MatchError("could not find instance group etcd in manifest"))
+
+					_, err = ops.Networks(manifest, "etcd")
+					Expect(err).To(MatchError("could not find instance group etcd in manifest"))
+
+					_, err = ops.VMType(manifest, "etcd")
+					Expect(err).To(MatchError("could not find instance group etcd in manifest"))
+
+					_, err = ops.PersistentDiskType(manifest, "etcd")
+					Expect(err).To(MatchError("could not find instance group etcd in manifest"))
+				})
+			})
+
+			Context("when the manifest yaml is invalid", func() {
+				It("returns an error", func() {
+					_, err := ops.ErrandInstanceGroups("%%%")
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+
+					_, err = ops.Releases("%%%")
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+
+					_, err = ops.Stemcells("%%%")
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+
+					_, err = ops.Jobs("%%%", "consul")
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+				})
+			})
+		})
+	})
 })
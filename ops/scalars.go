@@ -0,0 +1,181 @@
+package ops
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/cppforlife/go-patch/patch"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// sourceTree is the yaml.v3 node tree of the manifest a Document was parsed
+// from. It is only parsed when it is first needed and is shared by every
+// Document derived from the same source.
+type sourceTree struct {
+	source string
+	once   sync.Once
+	root   *yamlv3.Node
+	err    error
+}
+
+func newSourceTree(source string) *sourceTree {
+	return &sourceTree{source: source}
+}
+
+func (t *sourceTree) node() (*yamlv3.Node, error) {
+	t.once.Do(func() {
+		var document yamlv3.Node
+		t.err = yamlv3.Unmarshal([]byte(t.source), &document)
+		if t.err == nil && len(document.Content) > 0 {
+			t.root = document.Content[0]
+		}
+	})
+
+	return t.root, t.err
+}
+
+// restoreScalars takes the yaml.Marshal output of patched and writes every
+// scalar whose value is the same as in original the way the source spelled
+// it. Decoding into interface{} loses how a scalar was written, so without
+// this a version of 1.10 comes back as 1.1, "on" as true and 0755 as 493.
+// The output is always re-encoded, so it has the same layout whether or not
+// any scalar had to be restored.
+func (t *sourceTree) restoreScalars(output string, original, patched interface{}) (string, error) {
+	return t.restoreScalarsAt(patch.Pointer{}, output, original, patched)
+}
+
+// restoreScalarsAt restores the scalars of output from the part of the source
+// at path, where original is the value that was parsed from there.
+func (t *sourceTree) restoreScalarsAt(path patch.Pointer, output string, original, patched interface{}) (string, error) {
+	document, err := t.restoredDocumentAt(path, output, original, patched)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeDocument(output, document)
+}
+
+// restoredDocument parses output into a node tree and restores its scalars
+// from the source.
+func (t *sourceTree) restoredDocument(output string, original, patched interface{}) (*yamlv3.Node, error) {
+	return t.restoredDocumentAt(patch.Pointer{}, output, original, patched)
+}
+
+func (t *sourceTree) restoredDocumentAt(path patch.Pointer, output string, original, patched interface{}) (*yamlv3.Node, error) {
+	var document yamlv3.Node
+	err := yamlv3.Unmarshal([]byte(output), &document)
+	if err != nil {
+		// not tested
+		return nil, err
+	}
+
+	if t == nil || len(document.Content) == 0 {
+		return &document, nil
+	}
+
+	root, err := t.node()
+	if err != nil || root == nil {
+		// not tested
+		return &document, err
+	}
+
+	sourceNode := nodeAt(root, path)
+	if sourceNode == nil {
+		return &document, nil
+	}
+
+	restorer := scalarRestorer{}
+	restorer.restore(document.Content[0], sourceNode, original, patched)
+
+	return &document, nil
+}
+
+type scalarRestorer struct {
+	// keepTags restores only the spelling of a scalar and only where its tag
+	// still reads that spelling as the same value. A version of 1.10 then
+	// decodes into a string as "1.10" and into interface{} as the float it
+	// was parsed as.
+	keepTags bool
+}
+
+func (r *scalarRestorer) restore(node, sourceNode *yamlv3.Node, value, patchedValue interface{}) {
+	switch {
+	case node.Kind == yamlv3.ScalarNode && sourceNode.Kind == yamlv3.ScalarNode:
+		if !reflect.DeepEqual(value, patchedValue) {
+			return
+		}
+
+		if r.keepTags {
+			if readsAs(node.Tag, sourceNode.Value, patchedValue) {
+				node.Value = sourceNode.Value
+			}
+
+			return
+		}
+
+		node.Value = sourceNode.Value
+		node.Style = sourceNode.Style
+		node.Tag = sourceNode.Tag
+	case node.Kind == yamlv3.MappingNode && sourceNode.Kind == yamlv3.MappingNode:
+		typedValue, ok := value.(map[interface{}]interface{})
+		typedPatchedValue, patchedOk := patchedValue.(map[interface{}]interface{})
+		if !ok || !patchedOk {
+			return
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Kind != yamlv3.ScalarNode {
+				continue
+			}
+
+			key, err := nodeKey(node.Content[i])
+			if err != nil {
+				// not tested
+				continue
+			}
+
+			for j := 0; j+1 < len(sourceNode.Content); j += 2 {
+				sourceKey, err := nodeKey(sourceNode.Content[j])
+				if err != nil || !reflect.DeepEqual(key, sourceKey) {
+					continue
+				}
+
+				r.restore(node.Content[i], sourceNode.Content[j], key, sourceKey)
+
+				child, ok := typedValue[sourceKey]
+				if ok {
+					r.restore(node.Content[i+1], sourceNode.Content[j+1], child, typedPatchedValue[key])
+				}
+
+				break
+			}
+		}
+	case node.Kind == yamlv3.SequenceNode && sourceNode.Kind == yamlv3.SequenceNode:
+		typedValue, ok := value.([]interface{})
+		typedPatchedValue, patchedOk := patchedValue.([]interface{})
+		if !ok || !patchedOk || len(node.Content) != len(typedPatchedValue) || len(sourceNode.Content) != len(typedValue) {
+			return
+		}
+
+		used := map[int]bool{}
+		for i, item := range node.Content {
+			index := matchSequenceItem(typedValue, typedPatchedValue[i], i, used)
+			if index < 0 {
+				continue
+			}
+			used[index] = true
+
+			r.restore(item, sourceNode.Content[index], typedValue[index], typedPatchedValue[i])
+		}
+	}
+}
+
+func readsAs(tag, spelling string, value interface{}) bool {
+	node := yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: tag, Value: spelling}
+
+	var decoded interface{}
+	err := node.Decode(&decoded)
+
+	return err == nil && reflect.DeepEqual(decoded, value)
+}
@@ -0,0 +1,231 @@
+package ops_test
+
+import (
+	"github.com/pivotal-cf-experimental/destiny/ops"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("scalar fidelity", func() {
+	var manifest string
+
+	BeforeEach(func() {
+		manifest = `name: consul
+releases:
+- name: consul
+  version: 1.10
+  sha1: 0123456789
+- name: etcd
+  version: "1.10"
+- name: turbulence
+  version: 0.10
+stemcells:
+- alias: default
+  os: ubuntu-trusty
+  version: 3421.11
+instance_groups:
+- name: consul
+  azs: [z1]
+  instances: 3
+  jobs:
+  - name: consul_agent
+    release: consul
+  properties:
+    consul:
+      agent:
+        mode: server
+        enabled: on
+        verbose: yes
+        require_ssl: off
+        dns_config:
+          recursor_timeout: 5s
+      server_key_mode: 0600
+      file_mode: '0644'
+      consul_server: nil
+      empty: ~
+      ports: 8301:8301
+      build: 1e10
+      hash: 0x1F
+      date: 2017-01-01
+      y: n`
+	})
+
+	It("writes every scalar that no op changed the way the manifest wrote it", func() {
+		modifiedManifest, err := ops.ApplyOp(manifest, ops.Op{
+			Type:  "replace",
+			Path:  "/instance_groups/name=consul/instances",
+			Value: 5,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(modifiedManifest).To(Equal(`instance_groups:
+- azs:
+  - z1
+  instances: 5
+  jobs:
+  - name: consul_agent
+    release: consul
+  name: consul
+  properties:
+    consul:
+      y: n
+      agent:
+        dns_config:
+          recursor_timeout: 5s
+        enabled: on
+        mode: server
+        require_ssl: off
+        verbose: yes
+      build: 1e10
+      consul_server: nil
+      date: 2017-01-01
+      empty: ~
+      file_mode: '0644'
+      hash: 0x1F
+      ports: 8301:8301
+      server_key_mode: 0600
+name: consul
+releases:
+- name: consul
+  sha1: 0123456789
+  version: 1.10
+- name: etcd
+  version: "1.10"
+- name: turbulence
+  version: 0.10
+stemcells:
+- alias: default
+  os: ubuntu-trusty
+  version: 3421.11`))
+	})
+
+	It("writes values set by ops with their own type", func() {
+		modifiedManifest, err := ops.ApplyOps(manifest, []ops.Op{
+			{Type: "replace", Path: "/releases/name=consul/version", Value: "1.20"},
+			{Type: "replace", Path: "/releases/name=turbulence/version", Value: 0.2},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(modifiedManifest).To(ContainSubstring(`- name: consul
+  sha1: 0123456789
+  version: "1.20"`))
+		Expect(modifiedManifest).To(ContainSubstring(`- name: turbulence
+  version: 0.2`))
+	})
+
+	It("keeps the spelling of a value that an op set to the same value", func() {
+		modifiedManifest, err := ops.ApplyOp(manifest, ops.Op{
+			Type:  "replace",
+			Path:  "/releases/name=consul/version",
+			Value: 1.1,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(modifiedManifest).To(ContainSubstring("  version: 1.10\n"))
+	})
+
+	It("finds scalars the way the manifest wrote them", func() {
+		document, err := ops.ParseDocument(manifest)
+		Expect(err).NotTo(HaveOccurred())
+
+		document, err = document.ApplyOp(ops.Op{Type: "replace", Path: "/instance_groups/name=consul/instances", Value: 5})
+		Expect(err).NotTo(HaveOccurred())
+
+		version, _, err := document.FindString("/releases/name=consul/version")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(version).To(Equal("1.10"))
+
+		version, _, err = document.FindString("/releases/name=turbulence/version")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(version).To(Equal("0.10"))
+
+		version, _, err = ops.FindString(manifest, "/stemcells/alias=default/version")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(version).To(Equal("3421.11"))
+
+		enabled, _, err := document.FindBool("/instance_groups/name=consul/properties/consul/agent/enabled")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(enabled).To(BeTrue())
+
+		var mode string
+		_, err = document.FindInto("/instance_groups/name=consul/properties/consul/server_key_mode", &mode)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mode).To(Equal("0600"))
+
+		var releases []ops.Release
+		_, err = document.FindInto("/releases", &releases)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(releases[0].Version).To(Equal("1.10"))
+		Expect(releases[2].Version).To(Equal("0.10"))
+
+		versions, _, err := ops.FindStringSlice(`versions: [1.10, "1.11", 0.10]`, "/versions")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(Equal([]string{"1.10", "1.11", "0.10"}))
+	})
+
+	It("keeps the trailing line breaks of block scalars when scalars are restored", func() {
+		modifiedManifest, err := ops.ApplyOp(`certificate: |+
+  some-certificate
+
+version: 1.10
+name: some-name`, ops.Op{Type: "replace", Path: "/name", Value: "some-other-name"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(modifiedManifest).To(ContainSubstring("version: 1.10"))
+
+		certificate, err := ops.FindOp(modifiedManifest, "/certificate")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(certificate).To(Equal("some-certificate\n\n"))
+	})
+
+	It("decodes version pins the way the manifest wrote them", func() {
+		releases, err := ops.Releases(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(releases).To(Equal([]ops.Release{
+			{Name: "consul", Version: "1.10", SHA1: "0123456789"},
+			{Name: "etcd", Version: "1.10"},
+			{Name: "turbulence", Version: "0.10"},
+		}))
+
+		stemcells, err := ops.Stemcells(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stemcells[0].Version).To(Equal("3421.11"))
+	})
+
+	It("writes the same layout whether or not a scalar was restored", func() {
+		for _, version := range []string{"latest", "1.10"} {
+			modifiedManifest, err := ops.ApplyOps("releases:\n- name: consul\n  version: "+version+"\n", []ops.Op{
+				{Type: "replace", Path: "/name?", Value: "consul"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(modifiedManifest).To(Equal("name: consul\nreleases:\n- name: consul\n  version: " + version))
+		}
+	})
+
+	It("keeps the spelling of scalars that are not interpolated", func() {
+		interpolatedManifest, err := ops.Interpolate(manifest, ops.InterpolateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(interpolatedManifest).To(ContainSubstring("  version: 1.10\n"))
+		Expect(interpolatedManifest).To(ContainSubstring("  version: 0.10\n"))
+	})
+
+	It("keeps the spelling of scalars in every document of a stream", func() {
+		stream, err := ops.ParseStream("azs:\n- name: z1\n  enabled: yes\n---\n" + manifest)
+		Expect(err).NotTo(HaveOccurred())
+
+		stream, err = stream.ApplyOps(ops.DocumentNamed("consul"), []ops.Op{
+			{Type: "remove", Path: "/instance_groups"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		stream, err = stream.ApplyOps(ops.DocumentAt(0), []ops.Op{
+			{Type: "replace", Path: "/azs/0/name", Value: "z2"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		modifiedManifest, err := stream.Marshal()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(modifiedManifest).To(HavePrefix("azs:\n- enabled: yes\n  name: z2\n---\n"))
+		Expect(modifiedManifest).To(ContainSubstring("  version: 1.10\n"))
+	})
+})
@@ -0,0 +1,242 @@
+package ops
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DocumentSelector picks one document out of a stream, either by its position
+// or, when Name is set, by its top level name. The zero value selects the
+// first document.
+type DocumentSelector struct {
+	Index int
+	Name  string
+}
+
+func DocumentAt(index int) DocumentSelector {
+	return DocumentSelector{Index: index}
+}
+
+func DocumentNamed(name string) DocumentSelector {
+	return DocumentSelector{Name: name}
+}
+
+func (s DocumentSelector) String() string {
+	if s.Name != "" {
+		return fmt.Sprintf("named '%s'", s.Name)
+	}
+
+	return fmt.Sprintf("at index %d", s.Index)
+}
+
+// Stream is a "---" separated stream of YAML documents, such as a cloud config
+// followed by a deployment manifest. Like a Document, a Stream is never
+// modified in place.
+type Stream struct {
+	documents []Document
+	markers   []string
+	applied   []bool
+}
+
+func ParseStream(manifest string) (Stream, error) {
+	sources, markers := splitDocuments(manifest)
+
+	documents := []Document{}
+	for i, source := range sources {
+		document, err := parseDocument(source)
+		if err != nil {
+			return Stream{}, fmt.Errorf("document %d: %s", i, err)
+		}
+
+		documents = append(documents, document)
+	}
+
+	return Stream{
+		documents: documents,
+		markers:   markers,
+		applied:   make([]bool, len(documents)),
+	}, nil
+}
+
+func (s Stream) Documents() []Document {
+	return append([]Document{}, s.documents...)
+}
+
+func (s Stream) Document(selector DocumentSelector) (Document, error) {
+	index, err := s.index(selector)
+	if err != nil {
+		return Document{}, err
+	}
+
+	return s.documents[index], nil
+}
+
+// ApplyOps applies ops to the selected document and leaves the other documents
+// of the stream as they are.
+func (s Stream) ApplyOps(selector DocumentSelector, ops []Op) (Stream, error) {
+	stream, _, err := s.ApplyOpsWithReport(selector, ops)
+	return stream, err
+}
+
+func (s Stream) ApplyOpsWithReport(selector DocumentSelector, ops []Op) (Stream, ApplyReport, error) {
+	index, err := s.index(selector)
+	if err != nil {
+		return Stream{}, ApplyReport{}, err
+	}
+
+	document, report, err := s.documents[index].ApplyOpsWithReport(ops)
+	if err != nil {
+		return Stream{}, ApplyReport{}, err
+	}
+
+	documents := s.Documents()
+	documents[index] = document
+
+	applied := append([]bool{}, s.applied...)
+	applied[index] = true
+
+	return Stream{
+		documents: documents,
+		markers:   s.markers,
+		applied:   applied,
+	}, report, nil
+}
+
+func (s Stream) Marshal() (string, error) {
+	return Renderer{}.RenderStream(s)
+}
+
+// join renders the documents of the stream and puts the "---" lines of the
+// stream that was parsed back between them. With keepSources set, documents
+// that no ops were applied to are written exactly as they were parsed.
+func (s Stream) join(render func(Document) (string, error), keepSources bool) (string, error) {
+	documents := []string{}
+	for i, document := range s.documents {
+		if keepSources && !s.applied[i] {
+			documents = append(documents, document.source)
+			continue
+		}
+
+		if document.doc == nil && isBlankSource([]string{document.source}) {
+			documents = append(documents, "")
+			continue
+		}
+
+		output, err := render(document)
+		if err != nil {
+			return "", err
+		}
+
+		documents = append(documents, output)
+	}
+
+	// A document that ends in a block scalar keeps the line break that ends
+	// its last line, so the separator goes straight after it.
+	output := ""
+	for i, document := range documents {
+		if i > 0 && !strings.HasSuffix(documents[i-1], "\n") {
+			output += "\n"
+		}
+
+		output += s.markers[i] + document
+	}
+
+	return output, nil
+}
+
+func (s Stream) index(selector DocumentSelector) (int, error) {
+	if selector.Name == "" {
+		if selector.Index < 0 || selector.Index >= len(s.documents) {
+			return 0, documentNotFoundErr{selector: selector, count: len(s.documents)}
+		}
+
+		return selector.Index, nil
+	}
+
+	indexes := []int{}
+	for i, document := range s.documents {
+		name, err := document.Name()
+		if err == nil && name == selector.Name {
+			indexes = append(indexes, i)
+		}
+	}
+
+	switch len(indexes) {
+	case 0:
+		return 0, documentNotFoundErr{selector: selector, count: len(s.documents)}
+	case 1:
+		return indexes[0], nil
+	default:
+		return 0, fmt.Errorf("found %d documents named '%s' in stream", len(indexes), selector.Name)
+	}
+}
+
+// splitDocuments splits a stream at every "---" line and returns, for every
+// document, the text that came before it: its "---" line as it was written,
+// or nothing for a first document without one. Text before the first marker
+// and after the last one is only a document if it has any content besides
+// comments, otherwise text before the first marker is kept with that marker.
+// A marker followed by content is written as a bare "---" line, since the
+// content becomes part of the document. A "---" line can only start a
+// document because every line of a block scalar has to be indented.
+func splitDocuments(manifest string) ([]string, []string) {
+	sources := []string{}
+	markers := []string{}
+	marker := ""
+	current := []string{}
+	split := false
+
+	for _, line := range strings.SplitAfter(manifest, "\n") {
+		if !isDocumentMarker(line) {
+			current = append(current, line)
+			continue
+		}
+
+		if split || !isBlankSource(current) {
+			sources = append(sources, strings.Join(current, ""))
+			markers = append(markers, marker)
+			marker = ""
+		} else {
+			marker = strings.Join(current, "")
+		}
+		split = true
+
+		current = []string{}
+		if rest := strings.TrimSpace(line[3:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			current = append(current, rest+"\n")
+			line = "---\n"
+		}
+
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		marker += line
+	}
+
+	if len(sources) == 0 || !isBlankSource(current) {
+		sources = append(sources, strings.Join(current, ""))
+		markers = append(markers, marker)
+	}
+
+	return sources, markers
+}
+
+func isDocumentMarker(line string) bool {
+	if !strings.HasPrefix(line, "---") {
+		return false
+	}
+
+	rest := line[3:]
+	return rest == "" || strings.ContainsAny(rest[:1], " \t\r\n")
+}
+
+func isBlankSource(lines []string) bool {
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "%") {
+			return false
+		}
+	}
+
+	return true
+}
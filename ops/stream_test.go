@@ -0,0 +1,221 @@
+package ops_test
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stream", func() {
+	var manifest string
+
+	BeforeEach(func() {
+		manifest = `---
+azs:
+- name: z1
+vm_types:
+- name: default
+---
+name: consul
+instance_groups:
+- name: consul
+  azs: [z1]
+  instances: 3
+---
+name: etcd
+instance_groups:
+- name: etcd
+  azs: [z1]
+  instances: 1`
+	})
+
+	Describe("ParseStream", func() {
+		It("parses every document of the stream", func() {
+			stream, err := ops.ParseStream(manifest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stream.Documents()).To(HaveLen(3))
+		})
+
+		It("parses a single document without markers", func() {
+			stream, err := ops.ParseStream("name: consul")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stream.Documents()).To(HaveLen(1))
+		})
+
+		It("does not split at indented markers inside block scalars", func() {
+			stream, err := ops.ParseStream("name: consul\ncert: |\n  ---\n  some-cert\n---\nname: etcd")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stream.Documents()).To(HaveLen(2))
+
+			document, err := stream.Document(ops.DocumentAt(0))
+			Expect(err).NotTo(HaveOccurred())
+
+			cert, _, err := document.FindString("/cert")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cert).To(Equal("---\nsome-cert\n"))
+		})
+
+		It("reports which document failed to parse", func() {
+			_, err := ops.ParseStream("name: consul\n---\nname: [")
+			Expect(err).To(MatchError(ContainSubstring("document 1:")))
+		})
+	})
+
+	Describe("Document", func() {
+		It("selects a document by index", func() {
+			stream, err := ops.ParseStream(manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			document, err := stream.Document(ops.DocumentAt(2))
+			Expect(err).NotTo(HaveOccurred())
+
+			name, err := document.Name()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("etcd"))
+		})
+
+		It("selects a document by its top level name", func() {
+			stream, err := ops.ParseStream(manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			document, err := stream.Document(ops.DocumentNamed("consul"))
+			Expect(err).NotTo(HaveOccurred())
+
+			instanceGroups, err := document.InstanceGroups()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instanceGroups).To(HaveLen(1))
+			Expect(instanceGroups[0].Name).To(Equal("consul"))
+		})
+
+		It("returns an error when no document matches", func() {
+			stream, err := ops.ParseStream(manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = stream.Document(ops.DocumentNamed("turbulence"))
+			Expect(err).To(MatchError("could not find document named 'turbulence' in stream of 3 documents"))
+			Expect(errors.Is(err, ops.ErrDocumentNotFound)).To(BeTrue())
+
+			_, err = stream.Document(ops.DocumentAt(3))
+			Expect(err).To(MatchError("could not find document at index 3 in stream of 3 documents"))
+			Expect(errors.Is(err, ops.ErrDocumentNotFound)).To(BeTrue())
+		})
+
+		It("returns an error when several documents have the name", func() {
+			stream, err := ops.ParseStream("name: consul\n---\nname: consul")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = stream.Document(ops.DocumentNamed("consul"))
+			Expect(err).To(MatchError("found 2 documents named 'consul' in stream"))
+		})
+	})
+
+	Describe("ApplyOps", func() {
+		It("applies ops to the selected document only", func() {
+			stream, err := ops.ParseStream(manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			modifiedStream, err := stream.ApplyOps(ops.DocumentNamed("etcd"), []ops.Op{
+				{Type: "replace", Path: "/instance_groups/name=etcd/instances", Value: 3},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, s := range []ops.Stream{stream, modifiedStream} {
+				document, err := s.Document(ops.DocumentNamed("consul"))
+				Expect(err).NotTo(HaveOccurred())
+
+				instances, _, err := document.FindInt("/instance_groups/name=consul/instances")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(instances).To(Equal(3))
+			}
+
+			document, err := modifiedStream.Document(ops.DocumentNamed("etcd"))
+			Expect(err).NotTo(HaveOccurred())
+
+			instances, _, err := document.FindInt("/instance_groups/name=etcd/instances")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instances).To(Equal(3))
+		})
+	})
+
+	Describe("ApplyOpsToDocument", func() {
+		It("returns the whole stream with the document boundaries kept", func() {
+			modifiedManifest, err := ops.ApplyOpsToDocument(manifest, ops.DocumentAt(0), []ops.Op{
+				{Type: "replace", Path: "/azs/-", Value: map[string]string{"name": "z2"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(Equal(`---
+azs:
+- name: z1
+- name: z2
+vm_types:
+- name: default
+---
+name: consul
+instance_groups:
+- name: consul
+  azs: [z1]
+  instances: 3
+---
+name: etcd
+instance_groups:
+- name: etcd
+  azs: [z1]
+  instances: 1`))
+		})
+
+		It("writes the documents no ops were applied to as they were parsed", func() {
+			manifest = `# cloud config
+--- # azs
+azs:
+- name: z1
+--- # consul
+# some comment
+name: consul
+instance_groups:
+- {name: consul, instances: 3}
+`
+
+			modifiedManifest, err := ops.ApplyOpsToDocument(manifest, ops.DocumentAt(0), []ops.Op{
+				{Type: "replace", Path: "/azs/0/name", Value: "z2"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(Equal(`# cloud config
+--- # azs
+azs:
+- name: z2
+--- # consul
+# some comment
+name: consul
+instance_groups:
+- {name: consul, instances: 3}
+`))
+		})
+
+		It("keeps the layout of every document when asked to", func() {
+			modifiedManifest, err := ops.Renderer{
+				Options: ops.ApplyOptions{PreserveLayout: true},
+			}.ApplyOpsToDocument(manifest, ops.DocumentNamed("consul"), []ops.Op{
+				{Type: "replace", Path: "/instance_groups/name=consul/instances", Value: 5},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(Equal(strings.Replace(manifest, "instances: 3", "instances: 5", 1)))
+		})
+	})
+
+	Context("when a stream is given to a single document function", func() {
+		It("returns an error instead of using the first document", func() {
+			_, err := ops.ApplyOps(manifest, []ops.Op{{Type: "replace", Path: "/name", Value: "some-name"}})
+			Expect(err).To(MatchError("manifest contains 3 documents, use ParseStream to select one"))
+
+			_, err = ops.ManifestName(manifest)
+			Expect(err).To(MatchError("manifest contains 3 documents, use ParseStream to select one"))
+		})
+	})
+})
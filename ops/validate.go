@@ -0,0 +1,104 @@
+package ops
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ValidationProblem struct {
+	Path    string
+	Message string
+}
+
+func (p ValidationProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+type ValidationError struct {
+	Problems []ValidationProblem
+}
+
+func (e ValidationError) Error() string {
+	problems := []string{}
+	for _, problem := range e.Problems {
+		problems = append(problems, problem.String())
+	}
+
+	return fmt.Sprintf("manifest is invalid:\n- %s", strings.Join(problems, "\n- "))
+}
+
+func Validate(manifest string) error {
+	manifestStruct, err := ParseManifest(manifest)
+	if err != nil {
+		return err
+	}
+
+	problems := validateManifest(manifestStruct)
+	if len(problems) > 0 {
+		return ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+func validateManifest(manifest Manifest) []ValidationProblem {
+	problems := []ValidationProblem{}
+
+	stemcellAliases := map[string]bool{}
+	for _, stemcell := range manifest.Stemcells {
+		stemcellAliases[stemcell.Alias] = true
+	}
+
+	releaseNames := map[string]bool{}
+	for _, release := range manifest.Releases {
+		releaseNames[release.Name] = true
+	}
+
+	instanceGroupNames := map[string]bool{}
+	for i, instanceGroup := range manifest.InstanceGroups {
+		path := fmt.Sprintf("/instance_groups/%d", i)
+		if instanceGroup.Name != "" {
+			path = fmt.Sprintf("/instance_groups/name=%s", instanceGroup.Name)
+		}
+
+		if instanceGroupNames[instanceGroup.Name] {
+			problems = append(problems, ValidationProblem{
+				Path:    fmt.Sprintf("/instance_groups/%d/name", i),
+				Message: fmt.Sprintf("instance group name '%s' is not unique", instanceGroup.Name),
+			})
+		}
+		instanceGroupNames[instanceGroup.Name] = true
+
+		if !stemcellAliases[instanceGroup.Stemcell] {
+			problems = append(problems, ValidationProblem{
+				Path:    path + "/stemcell",
+				Message: fmt.Sprintf("stemcell alias '%s' is not declared under stemcells", instanceGroup.Stemcell),
+			})
+		}
+
+		if instanceGroup.Lifecycle != "errand" && len(instanceGroup.AZs) == 0 {
+			problems = append(problems, ValidationProblem{
+				Path:    path + "/azs",
+				Message: "azs must not be empty",
+			})
+		}
+
+		if instanceGroup.Instances < 0 {
+			problems = append(problems, ValidationProblem{
+				Path:    path + "/instances",
+				Message: fmt.Sprintf("instances must not be negative, found %d", instanceGroup.Instances),
+			})
+		}
+
+		for _, job := range instanceGroup.Jobs {
+			if !releaseNames[job.Release] {
+				problems = append(problems, ValidationProblem{
+					Path:    fmt.Sprintf("%s/jobs/name=%s/release", path, job.Name),
+					Message: fmt.Sprintf("release '%s' is not listed under releases", job.Release),
+				})
+			}
+		}
+	}
+
+	return problems
+}
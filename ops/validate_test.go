@@ -0,0 +1,95 @@
+package ops_test
+
+import (
+	"github.com/pivotal-cf-experimental/destiny/ops"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate", func() {
+	It("returns nil for a consistent manifest", func() {
+		err := ops.Validate(`
+name: some-name
+releases:
+- name: consul
+stemcells:
+- alias: default
+instance_groups:
+- name: consul
+  instances: 1
+  azs: [z1]
+  stemcell: default
+  jobs:
+  - name: consul_agent
+    release: consul
+- name: some-errand
+  instances: 0
+  lifecycle: errand
+  stemcell: default`)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("failure cases", func() {
+		It("returns every problem in the manifest", func() {
+			err := ops.Validate(`
+name: some-name
+releases:
+- name: consul
+stemcells:
+- alias: default
+instance_groups:
+- name: consul
+  instances: -1
+  azs: []
+  stemcell: windows
+  jobs:
+  - name: consul_agent
+    release: consul
+  - name: etcd
+    release: etcd
+- name: consul
+  instances: 1
+  azs: [z1]
+  stemcell: default`)
+			Expect(err).To(BeAssignableToTypeOf(ops.ValidationError{}))
+
+			Expect(err.(ops.ValidationError).Problems).To(Equal([]ops.ValidationProblem{
+				{
+					Path:    "/instance_groups/name=consul/stemcell",
+					Message: "stemcell alias 'windows' is not declared under stemcells",
+				},
+				{
+					Path:    "/instance_groups/name=consul/azs",
+					Message: "azs must not be empty",
+				},
+				{
+					Path:    "/instance_groups/name=consul/instances",
+					Message: "instances must not be negative, found -1",
+				},
+				{
+					Path:    "/instance_groups/name=consul/jobs/name=etcd/release",
+					Message: "release 'etcd' is not listed under releases",
+				},
+				{
+					Path:    "/instance_groups/1/name",
+					Message: "instance group name 'consul' is not unique",
+				},
+			}))
+
+			Expect(err).To(MatchError(`manifest is invalid:
+- /instance_groups/name=consul/stemcell: stemcell alias 'windows' is not declared under stemcells
+- /instance_groups/name=consul/azs: azs must not be empty
+- /instance_groups/name=consul/instances: instances must not be negative, found -1
+- /instance_groups/name=consul/jobs/name=etcd/release: release 'etcd' is not listed under releases
+- /instance_groups/1/name: instance group name 'consul' is not unique`))
+		})
+
+		Context("when the manifest yaml is invalid", func() {
+			It("returns an error", func() {
+				err := ops.Validate("%%%")
+				Expect(err).To(MatchError("yaml: could not find expected directive name"))
+			})
+		})
+	})
+})
@@ -0,0 +1,377 @@
+package ops
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	passwordLength    = 20
+	passwordAlphabet  = "abcdefghijklmnopqrstuvwxyz0123456789"
+	rsaKeyBits        = 2048
+	certificateExpiry = 365 * 24 * time.Hour
+)
+
+type Variable struct {
+	Name    string                 `yaml:"name"`
+	Type    string                 `yaml:"type"`
+	Options VariableOptions        `yaml:"options,omitempty"`
+	Extra   map[string]interface{} `yaml:",inline"`
+}
+
+type VariableOptions struct {
+	CA               string                 `yaml:"ca,omitempty"`
+	IsCA             bool                   `yaml:"is_ca,omitempty"`
+	CommonName       string                 `yaml:"common_name,omitempty"`
+	AlternativeNames []string               `yaml:"alternative_names,omitempty"`
+	ExtendedKeyUsage []string               `yaml:"extended_key_usage,omitempty"`
+	Extra            map[string]interface{} `yaml:",inline"`
+}
+
+type VarsStore struct {
+	Path string
+}
+
+func (s VarsStore) Load() (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+
+	contents, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return vars, nil
+		}
+
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(contents, &vars)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", s.Path, err)
+	}
+
+	return vars, nil
+}
+
+func (s VarsStore) Save(vars map[string]interface{}) error {
+	contents, err := yaml.Marshal(vars)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.Path, contents, 0600)
+}
+
+// Generate loads the store, generates a value for every variable in the
+// manifest's variables section that is not already stored, and saves the
+// result. Values that are already stored are returned unchanged.
+func (s VarsStore) Generate(manifest string) (map[string]interface{}, error) {
+	vars, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err = GenerateVariables(manifest, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Save(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
+
+func ManifestVariables(manifest string) ([]Variable, error) {
+	var manifestStruct struct {
+		Variables []Variable
+	}
+
+	err := yaml.Unmarshal([]byte(manifest), &manifestStruct)
+	if err != nil {
+		return []Variable{}, err
+	}
+
+	return manifestStruct.Variables, nil
+}
+
+func GenerateVariables(manifest string, existing map[string]interface{}) (map[string]interface{}, error) {
+	variables, err := ManifestVariables(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	generator := variableGenerator{
+		variables: map[string]Variable{},
+		vars:      map[string]interface{}{},
+	}
+
+	for name, value := range existing {
+		generator.vars[name] = value
+	}
+
+	for _, variable := range variables {
+		generator.variables[variable.Name] = variable
+	}
+
+	for _, variable := range variables {
+		_, err := generator.generate(variable.Name, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return generator.vars, nil
+}
+
+type variableGenerator struct {
+	variables map[string]Variable
+	vars      map[string]interface{}
+}
+
+func (g variableGenerator) generate(name string, seen []string) (interface{}, error) {
+	if value, ok := g.vars[name]; ok {
+		return value, nil
+	}
+
+	for _, seenName := range seen {
+		if seenName == name {
+			return nil, fmt.Errorf("variable '%s' has a circular ca reference", name)
+		}
+	}
+
+	variable, ok := g.variables[name]
+	if !ok {
+		return nil, fmt.Errorf("could not find variable '%s' in manifest", name)
+	}
+
+	var value interface{}
+	var err error
+	switch variable.Type {
+	case "password":
+		value, err = generatePassword()
+	case "rsa":
+		value, err = generateRSA()
+	case "ssh":
+		value, err = generateSSH()
+	case "certificate":
+		value, err = g.generateCertificate(variable, append(seen, name))
+	default:
+		return nil, fmt.Errorf("variable '%s' has unsupported type '%s'", name, variable.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("variable '%s': %s", name, err)
+	}
+
+	g.vars[name] = value
+
+	return value, nil
+}
+
+func generatePassword() (string, error) {
+	alphabetLength := big.NewInt(int64(len(passwordAlphabet)))
+
+	password := make([]byte, passwordLength)
+	for i := range password {
+		index, err := rand.Int(rand.Reader, alphabetLength)
+		if err != nil {
+			return "", err
+		}
+
+		password[i] = passwordAlphabet[index.Int64()]
+	}
+
+	return string(password), nil
+}
+
+func generateRSA() (map[interface{}]interface{}, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[interface{}]interface{}{
+		"private_key": encodePrivateKey(privateKey),
+		"public_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})),
+	}, nil
+}
+
+func generateSSH() (map[interface{}]interface{}, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey := sshPublicKey(&privateKey.PublicKey)
+
+	fingerprint := []string{}
+	for _, b := range md5.Sum(publicKey) {
+		fingerprint = append(fingerprint, fmt.Sprintf("%02x", b))
+	}
+
+	return map[interface{}]interface{}{
+		"private_key":            encodePrivateKey(privateKey),
+		"public_key":             "ssh-rsa " + base64.StdEncoding.EncodeToString(publicKey),
+		"public_key_fingerprint": strings.Join(fingerprint, ":"),
+	}, nil
+}
+
+func (g variableGenerator) generateCertificate(variable Variable, seen []string) (map[interface{}]interface{}, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: variable.Options.CommonName},
+		NotBefore:             now,
+		NotAfter:              now.Add(certificateExpiry),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  variable.Options.IsCA,
+	}
+
+	if variable.Options.IsCA {
+		template.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+
+	for _, alternativeName := range variable.Options.AlternativeNames {
+		if ip := net.ParseIP(alternativeName); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, alternativeName)
+		}
+	}
+
+	for _, usage := range variable.Options.ExtendedKeyUsage {
+		switch usage {
+		case "server_auth":
+			template.ExtKeyUsage = append(template.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+		case "client_auth":
+			template.ExtKeyUsage = append(template.ExtKeyUsage, x509.ExtKeyUsageClientAuth)
+		default:
+			return nil, fmt.Errorf("unsupported extended key usage '%s'", usage)
+		}
+	}
+
+	parent := template
+	signer := privateKey
+	caPEM := ""
+
+	if variable.Options.CA != "" {
+		caValue, err := g.generate(variable.Options.CA, seen)
+		if err != nil {
+			return nil, err
+		}
+
+		parent, signer, caPEM, err = parseCAValue(variable.Options.CA, caValue)
+		if err != nil {
+			return nil, err
+		}
+	} else if !variable.Options.IsCA {
+		return nil, fmt.Errorf("certificate must either be a ca or reference one")
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, parent, &privateKey.PublicKey, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	certificatePEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}))
+	if caPEM == "" {
+		caPEM = certificatePEM
+	}
+
+	return map[interface{}]interface{}{
+		"ca":          caPEM,
+		"certificate": certificatePEM,
+		"private_key": encodePrivateKey(privateKey),
+	}, nil
+}
+
+func parseCAValue(name string, value interface{}) (*x509.Certificate, *rsa.PrivateKey, string, error) {
+	var certificatePEM, privateKeyPEM string
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		certificatePEM, _ = typedValue["certificate"].(string)
+		privateKeyPEM, _ = typedValue["private_key"].(string)
+	case map[string]interface{}:
+		certificatePEM, _ = typedValue["certificate"].(string)
+		privateKeyPEM, _ = typedValue["private_key"].(string)
+	}
+
+	certificateBlock, _ := pem.Decode([]byte(certificatePEM))
+	if certificateBlock == nil {
+		return nil, nil, "", fmt.Errorf("ca '%s' does not have a PEM certificate", name)
+	}
+
+	certificate, err := x509.ParseCertificate(certificateBlock.Bytes)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("ca '%s': %s", name, err)
+	}
+
+	privateKeyBlock, _ := pem.Decode([]byte(privateKeyPEM))
+	if privateKeyBlock == nil {
+		return nil, nil, "", fmt.Errorf("ca '%s' does not have a PEM private key", name)
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(privateKeyBlock.Bytes)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("ca '%s': %s", name, err)
+	}
+
+	return certificate, privateKey, certificatePEM, nil
+}
+
+func encodePrivateKey(privateKey *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}))
+}
+
+func sshPublicKey(publicKey *rsa.PublicKey) []byte {
+	buffer := &bytes.Buffer{}
+	writeSSHBytes(buffer, []byte("ssh-rsa"))
+	writeSSHBytes(buffer, big.NewInt(int64(publicKey.E)).Bytes())
+	writeSSHBytes(buffer, append([]byte{0}, publicKey.N.Bytes()...))
+
+	return buffer.Bytes()
+}
+
+func writeSSHBytes(buffer *bytes.Buffer, contents []byte) {
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(contents)))
+
+	buffer.Write(length)
+	buffer.Write(contents)
+}
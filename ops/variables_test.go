@@ -0,0 +1,223 @@
+package ops_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+	"github.com/pivotal-cf-experimental/gomegamatchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Variables", func() {
+	var parseCertificate = func(certificatePEM interface{}) *x509.Certificate {
+		block, _ := pem.Decode([]byte(certificatePEM.(string)))
+		Expect(block).NotTo(BeNil())
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		Expect(err).NotTo(HaveOccurred())
+
+		return certificate
+	}
+
+	Describe("GenerateVariables", func() {
+		It("generates a value for every variable in the manifest", func() {
+			vars, err := ops.GenerateVariables(`
+variables:
+- name: consul_agent_cert
+  type: certificate
+  options:
+    ca: consul_ca
+    common_name: consul agent
+    alternative_names: [127.0.0.1, consul.service.cf.internal]
+    extended_key_usage: [client_auth, server_auth]
+- name: consul_ca
+  type: certificate
+  options:
+    is_ca: true
+    common_name: consulCA
+- name: consul_encrypt_key
+  type: password
+- name: turbulence_key
+  type: rsa
+- name: jumpbox_ssh
+  type: ssh`, map[string]interface{}{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(vars["consul_encrypt_key"]).To(MatchRegexp("^[a-z0-9]{20}$"))
+
+			Expect(vars["turbulence_key"]).To(HaveKeyWithValue("private_key", ContainSubstring("BEGIN RSA PRIVATE KEY")))
+			Expect(vars["turbulence_key"]).To(HaveKeyWithValue("public_key", ContainSubstring("BEGIN PUBLIC KEY")))
+
+			Expect(vars["jumpbox_ssh"]).To(HaveKeyWithValue("private_key", ContainSubstring("BEGIN RSA PRIVATE KEY")))
+			Expect(vars["jumpbox_ssh"]).To(HaveKeyWithValue("public_key", HavePrefix("ssh-rsa AAAAB3NzaC1yc2E")))
+			Expect(vars["jumpbox_ssh"]).To(HaveKeyWithValue("public_key_fingerprint", MatchRegexp("^([0-9a-f]{2}:){15}[0-9a-f]{2}$")))
+
+			ca := vars["consul_ca"].(map[interface{}]interface{})
+			caCertificate := parseCertificate(ca["certificate"])
+			Expect(caCertificate.IsCA).To(BeTrue())
+			Expect(caCertificate.Subject.CommonName).To(Equal("consulCA"))
+			Expect(ca["ca"]).To(Equal(ca["certificate"]))
+
+			agent := vars["consul_agent_cert"].(map[interface{}]interface{})
+			Expect(agent["ca"]).To(Equal(ca["certificate"]))
+			Expect(agent["private_key"]).To(ContainSubstring("BEGIN RSA PRIVATE KEY"))
+
+			agentCertificate := parseCertificate(agent["certificate"])
+			Expect(agentCertificate.IsCA).To(BeFalse())
+			Expect(agentCertificate.Subject.CommonName).To(Equal("consul agent"))
+			Expect(agentCertificate.DNSNames).To(Equal([]string{"consul.service.cf.internal"}))
+			Expect(agentCertificate.IPAddresses[0].String()).To(Equal("127.0.0.1"))
+			Expect(agentCertificate.ExtKeyUsage).To(Equal([]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}))
+			Expect(agentCertificate.CheckSignatureFrom(caCertificate)).To(Succeed())
+		})
+
+		It("reuses existing values unchanged", func() {
+			vars, err := ops.GenerateVariables(`
+variables:
+- name: some-password
+  type: password
+- name: some-other-password
+  type: password`, map[string]interface{}{
+				"some-password": "some-existing-password",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(vars["some-password"]).To(Equal("some-existing-password"))
+			Expect(vars["some-other-password"]).To(MatchRegexp("^[a-z0-9]{20}$"))
+		})
+
+		Context("failure cases", func() {
+			Context("when the variable type is not supported", func() {
+				It("returns an error", func() {
+					_, err := ops.GenerateVariables("variables: [{name: some-var, type: user}]", map[string]interface{}{})
+					Expect(err).To(MatchError("variable 'some-var' has unsupported type 'user'"))
+				})
+			})
+
+			Context("when a certificate references a missing ca", func() {
+				It("returns an error", func() {
+					_, err := ops.GenerateVariables("variables: [{name: some-cert, type: certificate, options: {ca: some-ca}}]", map[string]interface{}{})
+					Expect(err).To(MatchError("variable 'some-cert': could not find variable 'some-ca' in manifest"))
+				})
+			})
+
+			Context("when certificates reference each other", func() {
+				It("returns an error", func() {
+					_, err := ops.GenerateVariables(`
+variables:
+- {name: some-cert, type: certificate, options: {ca: some-other-cert}}
+- {name: some-other-cert, type: certificate, options: {ca: some-cert}}`, map[string]interface{}{})
+					Expect(err).To(MatchError("variable 'some-cert': variable 'some-other-cert': variable 'some-cert' has a circular ca reference"))
+				})
+			})
+
+			Context("when a certificate is neither a ca nor signed by one", func() {
+				It("returns an error", func() {
+					_, err := ops.GenerateVariables("variables: [{name: some-cert, type: certificate}]", map[string]interface{}{})
+					Expect(err).To(MatchError("variable 'some-cert': certificate must either be a ca or reference one"))
+				})
+			})
+
+			Context("when the extended key usage is not supported", func() {
+				It("returns an error", func() {
+					_, err := ops.GenerateVariables("variables: [{name: some-cert, type: certificate, options: {is_ca: true, extended_key_usage: [code_signing]}}]", map[string]interface{}{})
+					Expect(err).To(MatchError("variable 'some-cert': unsupported extended key usage 'code_signing'"))
+				})
+			})
+
+			Context("when the manifest yaml is invalid", func() {
+				It("returns an error", func() {
+					_, err := ops.GenerateVariables("%%%", map[string]interface{}{})
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+				})
+			})
+		})
+	})
+
+	Describe("VarsStore", func() {
+		var (
+			tempDir string
+			store   ops.VarsStore
+		)
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "vars-store")
+			Expect(err).NotTo(HaveOccurred())
+
+			store = ops.VarsStore{Path: filepath.Join(tempDir, "creds.yml")}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tempDir)).To(Succeed())
+		})
+
+		It("generates missing values and keeps stored values across runs", func() {
+			manifest := "variables:\n- {name: some-password, type: password}"
+
+			vars, err := store.Generate(manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			password := vars["some-password"]
+			Expect(password).To(MatchRegexp("^[a-z0-9]{20}$"))
+
+			vars, err = store.Generate(manifest + "\n- {name: some-other-password, type: password}")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(vars["some-password"]).To(Equal(password))
+			Expect(vars).To(HaveKey("some-other-password"))
+
+			storedVars, err := store.Load()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(storedVars).To(Equal(vars))
+		})
+
+		It("is used by Interpolate without storing explicitly provided vars", func() {
+			manifest := `
+name: ((deployment_name))
+properties:
+  password: ((some-password))
+variables:
+- name: some-password
+  type: password`
+
+			interpolatedManifest, err := ops.Interpolate(manifest, ops.InterpolateOptions{
+				VarsStore: store.Path,
+				Vars: map[string]interface{}{
+					"deployment_name": "some-name",
+				},
+				Strict: true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			storedVars, err := store.Load()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(storedVars).To(HaveLen(1))
+
+			Expect(interpolatedManifest).To(gomegamatchers.MatchYAML(`
+name: some-name
+properties:
+  password: ` + storedVars["some-password"].(string) + `
+variables:
+- name: some-password
+  type: password`))
+		})
+
+		Context("failure cases", func() {
+			Context("when the store is not a map", func() {
+				It("returns an error", func() {
+					Expect(ioutil.WriteFile(store.Path, []byte("- some-var"), 0600)).To(Succeed())
+
+					_, err := store.Load()
+					Expect(err).To(MatchError(ContainSubstring(store.Path + ": yaml: unmarshal errors")))
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,208 @@
+package ops
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cppforlife/go-patch/patch"
+)
+
+const wildcard = "*"
+
+// Match is a value found by FindAll together with the concrete path it was
+// found at.
+type Match struct {
+	Path  string
+	Value interface{}
+}
+
+func FindAll(manifest, path string) ([]Match, error) {
+	document, err := ParseDocument(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return document.FindAll(path)
+}
+
+// FindAll returns every value whose path matches path. A "*" segment matches
+// every item of an array or every key of a map. Everything before the first
+// "*" has to exist, while the items that the rest of the path cannot be
+// followed into are left out.
+func (d Document) FindAll(path string) ([]Match, error) {
+	pointer, err := patch.NewPointerFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := expandWildcards(d.doc, pointer, false)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []Match{}
+	for _, concretePath := range paths {
+		value, err := patch.FindOp{Path: concretePath}.Apply(d.doc)
+		if err != nil {
+			// not tested
+			return nil, err
+		}
+
+		matches = append(matches, Match{
+			Path:  concretePath.String(),
+			Value: copyValue(value),
+		})
+	}
+
+	return matches, nil
+}
+
+func hasWildcard(pointer patch.Pointer) bool {
+	for _, token := range pointer.Tokens() {
+		if isWildcard(token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isWildcard(token patch.Token) bool {
+	keyToken, ok := token.(patch.KeyToken)
+	return ok && keyToken.Key == wildcard && !keyToken.Optional
+}
+
+// expandOp turns an op with a wildcard path into one op per match. Replace and
+// merge ops match wherever the parent of the final segment exists, the other
+// op types only where the whole path exists. Remove ops are returned last
+// match first so that removing array items does not shift the ones still to
+// be removed.
+func expandOp(doc interface{}, op Op) ([]Op, error) {
+	pointer, err := patch.NewPointerFromString(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasWildcard(pointer) {
+		return []Op{op}, nil
+	}
+
+	paths, err := expandWildcards(doc, pointer, op.Type == "replace" || op.Type == "merge")
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []Op{}
+	for _, path := range paths {
+		expandedOp := op
+		expandedOp.Path = path.String()
+		ops = append(ops, expandedOp)
+	}
+
+	if op.Type == "remove" {
+		for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+			ops[i], ops[j] = ops[j], ops[i]
+		}
+	}
+
+	return ops, nil
+}
+
+func expandWildcards(doc interface{}, pointer patch.Pointer, parentOnly bool) ([]patch.Pointer, error) {
+	tokens := pointer.Tokens()
+
+	first := len(tokens)
+	for i, token := range tokens {
+		if isWildcard(token) {
+			first = i
+			break
+		}
+	}
+
+	prefix := patch.NewPointer(tokens[:first])
+	if first == len(tokens) {
+		if !pointerExists(doc, pointer, parentOnly) {
+			return []patch.Pointer{}, nil
+		}
+
+		if parentOnly {
+			return []patch.Pointer{optionalLast(pointer)}, nil
+		}
+
+		return []patch.Pointer{pointer}, nil
+	}
+
+	value, err := patch.FindOp{Path: prefix}.Apply(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []patch.Pointer{}
+	for _, child := range wildcardChildren(value) {
+		childTokens := append(append([]patch.Token{}, tokens[:first]...), child)
+		childTokens = append(childTokens, tokens[first+1:]...)
+
+		childPaths, err := expandWildcards(doc, patch.NewPointer(childTokens), parentOnly)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, childPaths...)
+	}
+
+	return paths, nil
+}
+
+func wildcardChildren(value interface{}) []patch.Token {
+	children := []patch.Token{}
+
+	switch typedValue := value.(type) {
+	case []interface{}:
+		for i := range typedValue {
+			children = append(children, patch.IndexToken{Index: i})
+		}
+	case map[interface{}]interface{}:
+		keys := []string{}
+		for key := range typedValue {
+			keys = append(keys, fmt.Sprint(key))
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			children = append(children, patch.KeyToken{Key: key})
+		}
+	}
+
+	return children
+}
+
+// optionalLast marks the final token of a pointer optional, so that a replace
+// can create it when only its parent exists.
+func optionalLast(pointer patch.Pointer) patch.Pointer {
+	tokens := append([]patch.Token{}, pointer.Tokens()...)
+
+	switch typedToken := tokens[len(tokens)-1].(type) {
+	case patch.KeyToken:
+		typedToken.Optional = true
+		tokens[len(tokens)-1] = typedToken
+	case patch.MatchingIndexToken:
+		typedToken.Optional = true
+		tokens[len(tokens)-1] = typedToken
+	}
+
+	return patch.NewPointer(tokens)
+}
+
+func pointerExists(doc interface{}, pointer patch.Pointer, parentOnly bool) bool {
+	tokens := pointer.Tokens()
+	if parentOnly {
+		if isOptional(pointer) {
+			return true
+		}
+
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	_, err := patch.FindOp{Path: patch.NewPointer(tokens)}.Apply(doc)
+	return err == nil
+}
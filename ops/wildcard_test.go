@@ -0,0 +1,154 @@
+package ops_test
+
+import (
+	"github.com/pivotal-cf-experimental/destiny/ops"
+	"github.com/pivotal-cf-experimental/gomegamatchers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Wildcards", func() {
+	var manifest string
+
+	BeforeEach(func() {
+		manifest = `name: some-name
+instance_groups:
+- name: consul
+  azs: [z1]
+  jobs:
+  - name: consul_agent
+    properties: {}
+- name: etcd
+  azs: [z1]
+  jobs:
+  - name: etcd
+- name: testconsumer
+  azs: [z1]
+  jobs:
+  - name: consul_agent
+    properties: {}`
+	})
+
+	Describe("ApplyOps", func() {
+		It("replaces the value at every match", func() {
+			modifiedManifest, err := ops.ApplyOps(manifest, []ops.Op{
+				{Type: "replace", Path: "/instance_groups/*/azs", Value: []string{"z2", "z3"}},
+				{Type: "replace", Path: "/instance_groups/*/jobs/name=consul_agent/properties/domain", Value: "cf.internal"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(gomegamatchers.MatchYAML(`name: some-name
+instance_groups:
+- name: consul
+  azs: [z2, z3]
+  jobs:
+  - name: consul_agent
+    properties: {domain: cf.internal}
+- name: etcd
+  azs: [z2, z3]
+  jobs:
+  - name: etcd
+- name: testconsumer
+  azs: [z2, z3]
+  jobs:
+  - name: consul_agent
+    properties: {domain: cf.internal}`))
+		})
+
+		It("removes every match", func() {
+			modifiedManifest, err := ops.ApplyOps(manifest, []ops.Op{
+				{Type: "remove", Path: "/instance_groups/*/jobs/name=consul_agent"},
+				{Type: "remove", Path: "/instance_groups/*/azs/*"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(gomegamatchers.MatchYAML(`name: some-name
+instance_groups:
+- name: consul
+  azs: []
+  jobs: []
+- name: etcd
+  azs: []
+  jobs:
+  - name: etcd
+- name: testconsumer
+  azs: []
+  jobs: []`))
+		})
+
+		It("does nothing when there are no matches", func() {
+			modifiedManifest, err := ops.ApplyOps("instance_groups: []", []ops.Op{
+				{Type: "replace", Path: "/instance_groups/*/azs", Value: []string{"z2"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(modifiedManifest).To(Equal("instance_groups: []"))
+		})
+
+		Context("failure cases", func() {
+			Context("when the path before the wildcard does not exist", func() {
+				It("returns an error", func() {
+					_, err := ops.ApplyOps(manifest, []ops.Op{
+						{Type: "replace", Path: "/instance_groupz/*/azs", Value: []string{"z2"}},
+					})
+					Expect(err).To(MatchError("op 0 (replace '/instance_groupz/*/azs') failed: Expected to find a map key 'instance_groupz' for path '/instance_groupz' (found map keys: 'instance_groups', 'name') (parent at line 1, column 1), did you mean 'instance_groups'?"))
+				})
+			})
+
+			Context("when a test op fails for one of the matches", func() {
+				It("returns an error", func() {
+					_, err := ops.ApplyOps(manifest, []ops.Op{
+						{Type: "test", Path: "/instance_groups/*/jobs/0/name", Value: "consul_agent"},
+					})
+					Expect(err).To(MatchError("op 0 (test '/instance_groups/*/jobs/0/name') failed: test op failed: expected '/instance_groups/1/jobs/0/name' to be 'consul_agent' but found 'etcd'"))
+				})
+			})
+		})
+	})
+
+	Describe("FindAll", func() {
+		It("returns every matching value with its concrete path", func() {
+			matches, err := ops.FindAll(manifest, "/instance_groups/*/jobs/name=consul_agent/properties")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(matches).To(Equal([]ops.Match{
+				{Path: "/instance_groups/0/jobs/name=consul_agent/properties", Value: map[interface{}]interface{}{}},
+				{Path: "/instance_groups/2/jobs/name=consul_agent/properties", Value: map[interface{}]interface{}{}},
+			}))
+		})
+
+		It("matches every key of a map", func() {
+			matches, err := ops.FindAll("properties: {b: 2, a: 1}", "/properties/*")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(matches).To(Equal([]ops.Match{
+				{Path: "/properties/a", Value: 1},
+				{Path: "/properties/b", Value: 2},
+			}))
+		})
+
+		It("returns the value at a path without wildcards", func() {
+			matches, err := ops.FindAll(manifest, "/name")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(matches).To(Equal([]ops.Match{{Path: "/name", Value: "some-name"}}))
+		})
+
+		Context("failure cases", func() {
+			Context("when the path is bad", func() {
+				It("returns an error", func() {
+					_, err := ops.FindAll(manifest, "instance_groups/*")
+					Expect(err).To(MatchError("Expected to start with '/'"))
+				})
+			})
+
+			Context("when the manifest yaml is invalid", func() {
+				It("returns an error", func() {
+					_, err := ops.FindAll("%%%", "/instance_groups/*")
+					Expect(err).To(MatchError("yaml: could not find expected directive name"))
+				})
+			})
+		})
+	})
+})
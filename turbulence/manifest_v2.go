@@ -1,6 +1,14 @@
 package turbulence
 
-import "github.com/pivotal-cf-experimental/destiny/ops"
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/destiny/ops"
+)
+
+var manifestV2Template = ops.NewTemplate(manifestV2)
 
 type ConfigV2 struct {
 	Name             string
@@ -9,15 +17,41 @@ type ConfigV2 struct {
 	DirectorUsername string
 	DirectorPassword string
 	DirectorCACert   string
+	Format           ops.OutputFormat
 }
 
 func NewManifestV2(config ConfigV2) (string, error) {
-	return ops.ApplyOps(manifestV2, []ops.Op{
-		{"replace", "/name", config.Name},
-		{"replace", "/instance_groups/name=api/azs", config.AZs},
-		{"replace", "/instance_groups/name=api/properties/director/host", config.DirectorHost},
-		{"replace", "/instance_groups/name=api/properties/director/client", config.DirectorUsername},
-		{"replace", "/instance_groups/name=api/properties/director/client_secret", config.DirectorPassword},
-		{"replace", "/instance_groups/name=api/properties/director/cert/ca", config.DirectorCACert},
+	buffer := &bytes.Buffer{}
+	err := WriteManifestV2(buffer, config)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+func WriteManifestV2(w io.Writer, config ConfigV2) error {
+	document, err := manifestV2Template.Document()
+	if err != nil {
+		return err
+	}
+
+	document, err = document.ApplyOps([]ops.Op{
+		{Type: "replace", Path: "/name", Value: config.Name},
+		{Type: "replace", Path: "/instance_groups/name=api/azs", Value: config.AZs},
+		{Type: "replace", Path: "/instance_groups/name=api/properties/director/host", Value: config.DirectorHost},
+		{Type: "replace", Path: "/instance_groups/name=api/properties/director/client", Value: config.DirectorUsername},
+		{Type: "replace", Path: "/instance_groups/name=api/properties/director/client_secret", Value: config.DirectorPassword},
+		{Type: "replace", Path: "/instance_groups/name=api/properties/director/cert/ca", Value: config.DirectorCACert},
 	})
+	if err != nil {
+		return err
+	}
+
+	err = document.Validate()
+	if err != nil {
+		return err
+	}
+
+	return ops.Renderer{Format: config.Format, Conventional: true}.WriteDocument(w, document)
 }
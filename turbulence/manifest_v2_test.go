@@ -1,8 +1,10 @@
 package turbulence_test
 
 import (
+	"bytes"
 	"io/ioutil"
 
+	"github.com/pivotal-cf-experimental/destiny/ops"
 	"github.com/pivotal-cf-experimental/destiny/turbulence"
 	"github.com/pivotal-cf-experimental/gomegamatchers"
 
@@ -28,5 +30,38 @@ var _ = Describe("ManifestV2", func() {
 
 			Expect(manifest).To(gomegamatchers.MatchYAML(turbulenceManifest))
 		})
+
+		Context("failure cases", func() {
+			Context("when no azs are provided", func() {
+				It("returns a validation error", func() {
+					_, err := turbulence.NewManifestV2(turbulence.ConfigV2{
+						Name: "turbulence",
+					})
+					Expect(err).To(MatchError(ContainSubstring("/instance_groups/name=api/azs: azs must not be empty")))
+				})
+			})
+		})
+	})
+
+	Describe("WriteManifestV2", func() {
+		It("writes the turbulence manifest into the writer", func() {
+			turbulenceManifest, err := ioutil.ReadFile("fixtures/turbulence_manifest_v2.yml")
+			Expect(err).NotTo(HaveOccurred())
+
+			buffer := &bytes.Buffer{}
+			err = turbulence.WriteManifestV2(buffer, turbulence.ConfigV2{
+				Name:             "turbulence",
+				AZs:              []string{"z1"},
+				DirectorHost:     "some-director-host",
+				DirectorUsername: "some-director-user",
+				DirectorPassword: "some-director-password",
+				DirectorCACert:   "some-director-ca-cert",
+				Format:           ops.CompactJSONOutput,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(HavePrefix(`{"instance_groups":[{`))
+			Expect(buffer.String()).To(gomegamatchers.MatchYAML(turbulenceManifest))
+		})
 	})
 })